@@ -2,18 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/gofiber/fiber/v3"
+
 	"github.com/lavish440/Home-Security-Chatbot/internal/app"
 	"github.com/lavish440/Home-Security-Chatbot/internal/config"
 )
 
 func main() {
-	cfg := config.Config
+	cfg := config.Init()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -23,21 +27,45 @@ func main() {
 		log.Fatalf("failed to initialize app: %v", err)
 	}
 
+	shutdownDone := make(chan struct{})
+
 	go func() {
+		defer close(shutdownDone)
+
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 		sig := <-sigChan
 		log.Printf("Received signal: %v. Shutting down...", sig)
 
-		if err := appInstance.Shutdown(); err != nil {
+		if err := appInstance.ShutdownWithTimeout(cfg.ShutdownTimeout); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("Drain timeout of %s exceeded, force-closing %d connections", cfg.ShutdownTimeout, appInstance.Server().GetOpenConnectionsCount())
+				return
+			}
 			log.Printf("Error during shutdown: %v", err)
 		}
 	}()
 
 	log.Printf("Server starting on port %s", cfg.Port)
 
-	if err := appInstance.Listen("localhost:" + cfg.Port); err != nil {
+	// Only applies when this process terminates TLS. Behind a TLS-terminating
+	// proxy leave the certificate unset and configure TLS on the proxy.
+	listenConfig := fiber.ListenConfig{}
+	if cfg.TLSCertFile != "" {
+		listenConfig.CertFile = cfg.TLSCertFile
+		listenConfig.CertKeyFile = cfg.TLSKeyFile
+		listenConfig.TLSMinVersion = cfg.TLSMinVersion
+		listenConfig.TLSConfigFunc = func(tlsConfig *tls.Config) {
+			if len(cfg.TLSCipherSuites) > 0 {
+				tlsConfig.CipherSuites = cfg.TLSCipherSuites
+			}
+		}
+	}
+
+	if err := appInstance.Listen("localhost:"+cfg.Port, listenConfig); err != nil {
 		log.Fatalf("server failed: %v", err)
 	}
+
+	<-shutdownDone
 }
@@ -2,6 +2,15 @@ package app
 
 import (
 	"context"
+	_ "embed"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -13,38 +22,160 @@ import (
 	"github.com/lavish440/Home-Security-Chatbot/internal/services"
 )
 
+//go:embed fallback.html
+var fallbackPage []byte
+
 func New(ctx context.Context, cfg *models.Config) (*fiber.App, error) {
 	app := fiber.New()
 
-	aiService, err := services.NewAIService(ctx, cfg.GeminiAPIKey)
+	// Handlers and middleware read the configuration through live, which a reload swaps
+	live := &atomic.Pointer[models.Config]{}
+	live.Store(cfg)
+
+	// Capture logs first so the stream includes startup messages
+	var logHandler *handlers.LogHandler
+	if cfg.EnableDebug {
+		logStream := services.NewLogStream(cfg.LogStreamSize, services.NewRedactor(cfg.RedactPatterns))
+		log.SetOutput(io.MultiWriter(log.Writer(), logStream))
+		logHandler = handlers.NewLogHandler(logStream)
+	}
+
+	if cfg.OTelEndpoint != "" {
+		telemetry, err := services.NewTelemetry(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		app.Hooks().OnPostShutdown(func(error) error {
+			return telemetry.Shutdown(context.Background())
+		})
+	}
+
+	aiService, err := services.NewAIService(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	sessionService := services.NewSessionService()
+	if cfg.StartupWarmup {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			aiService.WarmUp(ctx)
+		}()
+	}
+
+	// memory is the only SESSION_STORE backend so far, other values fail at load time
+	sessionService := services.NewSessionService(services.NewMemoryStore())
+	budgetService := services.NewBudgetService(cfg.DailyRequestBudget, cfg.DailyTokenBudget)
+	statsService := services.NewStatsService(5 * time.Minute)
+
+	loadMonitor := services.NewLoadMonitor(cfg.CompressionFastLoad, cfg.CompressionOffLoad)
+	if cfg.AdaptiveCompression {
+		loadMonitor.Start(5 * time.Second)
+	}
+
+	chatHandler := handlers.NewChatHandler(aiService, sessionService, budgetService, statsService, live)
+	debugHandler := handlers.NewDebugHandler(sessionService, aiService, loadMonitor, statsService, live)
+	profileHandler := handlers.NewProfileHandler(aiService)
+	budgetHandler := handlers.NewBudgetHandler(budgetService)
+	configHandler := handlers.NewConfigHandler(live,
+		aiService.Reconfigure,
+		chatHandler.Reconfigure,
+		func(cfg *models.Config) { budgetService.SetLimits(cfg.DailyRequestBudget, cfg.DailyTokenBudget) },
+	)
 
-	chatHandler := handlers.NewChatHandler(aiService, sessionService)
-	debugHandler := handlers.NewDebugHandler(sessionService)
+	var deadLetters *services.DeadLetterLog
+	if cfg.DeadLetterDir != "" {
+		if deadLetters, err = services.NewDeadLetterLog(cfg.DeadLetterDir, cfg.DeadLetterRetention); err != nil {
+			return nil, err
+		}
+	}
 
-	app.Use("/", static.New("./static"))
-	app.Post("/api/chat", chatHandler.Handle)
+	middleware.Register(app, live, loadMonitor, deadLetters, middleware.Emergency("/api/chat", chatHandler.DetectEmergency))
 
-	middleware.Register(app, cfg)
+	registerStatic(app, cfg)
+	// Routes that call the model share one chain, so its limits count their requests together.
+	// Limits run in order before the handler, the global bucket last so rejected requests don't spend its tokens.
+	// The global limiter is always installed so a reload can turn it on, the session limiter only with a session cookie.
+	modelRoute := []any{chatHandler.RequireReady}
+	if cfg.SessionCookieName != "" {
+		modelRoute = append(modelRoute, middleware.SessionLimiter(cfg.SessionCookieName, live))
+	}
+	modelRoute = append(modelRoute, middleware.GlobalLimiter(live))
+	app.Post("/api/chat", modelRoute[0], slices.Concat(modelRoute[1:], []any{chatHandler.Handle})...)
+	app.Post("/api/rephrase", modelRoute[0], slices.Concat(modelRoute[1:], []any{chatHandler.Rephrase})...)
+	app.Post("/api/validate", chatHandler.Validate)
+	app.Get("/api/summary", chatHandler.Summary)
+	app.Get("/api/profiles", profileHandler.List)
+	app.Get("/api/budget", budgetHandler.Remaining)
+	app.Get("/api/config", configHandler.Limits)
 
 	if cfg.EnableDebug {
-		app.Get(
-			"/api/debug/sessions",
-			middleware.BasicAuth(cfg.BasicAuthUser, cfg.BasicAuthPass),
-			debugHandler.SessionsDump,
-		)
+		debug := app.Group("/api/debug", middleware.BasicAuth(cfg.BasicAuthUser, cfg.BasicAuthPass))
+		debug.Get("/sessions", debugHandler.SessionsDump)
+		debug.Get("/sessions/:key/tokens", debugHandler.SessionTokens)
+		debug.Get("/sessions/:key/replay", debugHandler.SessionReplay)
+		debug.Put("/sessions/:key/tier", debugHandler.SetSessionTier)
+		debug.Post("/cleanup/pause", debugHandler.PauseCleanup)
+		debug.Post("/cleanup/resume", debugHandler.ResumeCleanup)
+		debug.Post("/warm", debugHandler.WarmModel)
+		debug.Get("/compression", debugHandler.Compression)
+		debug.Get("/dashboard", debugHandler.Dashboard)
+		debug.Post("/reload", configHandler.Reload)
+		app.Get(middleware.LogStreamPath, middleware.BasicAuth(cfg.BasicAuthUser, cfg.BasicAuthPass), logHandler.Stream)
+	}
+
+	var exportWebhook *services.ExportWebhook
+	if cfg.ExportWebhookURL != "" {
+		exportWebhook = services.NewExportWebhook(cfg.ExportWebhookURL, services.NewRedactor(cfg.RedactPatterns))
+		sessionService.OnReplace(exportWebhook.Export)
 	}
 
 	go func() {
 		ticker := time.NewTicker(10 * time.Minute)
 		for range ticker.C {
-			sessionService.Cleanup(30 * time.Minute)
+			for _, cs := range sessionService.Cleanup(live.Load().SessionIdleTimeout) {
+				if exportWebhook != nil {
+					exportWebhook.Export(cs)
+				}
+			}
 		}
 	}()
 
 	return app, nil
 }
+
+func registerStatic(app *fiber.App, cfg *models.Config) {
+	for host, dir := range cfg.StaticHosts {
+		app.Use("/", static.New(dir, static.Config{
+			Next: func(c fiber.Ctx) bool {
+				return strings.ToLower(c.Hostname()) != host
+			},
+		}))
+	}
+
+	// Unmapped hosts fall back to the default static directory
+	app.Use("/", static.New(cfg.StaticDir, static.Config{
+		Next: func(c fiber.Ctx) bool {
+			_, mapped := cfg.StaticHosts[strings.ToLower(c.Hostname())]
+			return mapped
+		},
+	}))
+
+	if !cfg.StaticFallback {
+		return
+	}
+
+	for _, dir := range append(slices.Collect(maps.Values(cfg.StaticHosts)), cfg.StaticDir) {
+		if _, err := os.Stat(filepath.Join(dir, "index.html")); err != nil {
+			log.Printf("WARNING: %s has no index.html, serving the fallback page: %v", dir, err)
+		}
+	}
+
+	// Only reached when the static middleware found no index to serve
+	app.Get("/", func(c fiber.Ctx) error {
+		c.Type("html", "utf-8")
+		return c.Status(fiber.StatusServiceUnavailable).Send(fallbackPage)
+	})
+}
@@ -2,45 +2,655 @@ package config
 
 import (
 	"cmp"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"math"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/joho/godotenv"
 
 	"github.com/lavish440/Home-Security-Chatbot/internal/models"
 )
 
+const defaultEmergencyMessage = "**If you are in immediate danger, leave if it is safe to do so and call your local emergency number (such as 911 or 112) now.** Do not confront an intruder."
+
+const defaultStartupMessage = "The assistant is still starting up. Please try again in a few seconds."
+
+var defaultLogRedactFields = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"api_key",
+	"password",
+	"secret",
+	"token",
+}
+
+var defaultLowQualityPhrases = []string{
+	"I'm not sure",
+	"I am not sure",
+	"I don't know",
+	"I do not know",
+	"I can't help with that",
+	"I cannot help with that",
+}
+
+var defaultDeviceBrands = []string{
+	"ADT",
+	"Abode",
+	"Amcrest",
+	"Arlo",
+	"August",
+	"Blink",
+	"Eufy",
+	"Hikvision",
+	"Honeywell",
+	"Kwikset",
+	"Lorex",
+	"Nest",
+	"Reolink",
+	"Ring",
+	"Schlage",
+	"SimpliSafe",
+	"Swann",
+	"UniFi",
+	"Vivint",
+	"Wyze",
+	"Yale",
+}
+
+var defaultEmergencyKeywords = []string{
+	"breaking in",
+	"broke in",
+	"break-in in progress",
+	"someone is in my house",
+	"someone is in my home",
+	"intruder in my",
+	"home invasion",
+	"being robbed",
+	"house is on fire",
+	"smell gas",
+}
+
 var (
 	envOnce sync.Once
 	Config  *models.Config
 )
 
-func init() {
+// Init reads .env and loads the configuration once, exiting on invalid
+// settings. It is called explicitly rather than from init so that importing
+// the package, e.g. from tests, has no side effects.
+func Init() *models.Config {
 	envOnce.Do(func() {
-		if err := godotenv.Load(); err != nil {
+		if err := loadDotenv(); err != nil {
 			log.Print("No .env file found")
 		}
 
-		Config = &models.Config{
-			Port:           getEnv("PORT", "3000"),
-			GeminiAPIKey:   os.Getenv("GEMINI_API_KEY"),
-			Origin:         os.Getenv("ORIGIN"),
-			ReverseProxyIP: os.Getenv("REVERSE_PROXY_IP"),
-			BasicAuthUser:  os.Getenv("BASIC_AUTH_USER"),
-			BasicAuthPass:  os.Getenv("BASIC_AUTH_PASS"),
+		cfg, err := Load()
+		if err != nil {
+			log.Fatal(err)
+		}
+		Config = cfg
+	})
+
+	return Config
+}
+
+// isProduction reports whether APP_ENV marks this process as a production deployment.
+func isProduction() bool {
+	return strings.EqualFold(os.Getenv("APP_ENV"), "production")
+}
+
+// dotenvEnabled reports whether .env files are read: LOAD_DOTENV when it is
+// set, otherwise everywhere except APP_ENV=production.
+func dotenvEnabled() bool {
+	if v := os.Getenv("LOAD_DOTENV"); v != "" {
+		return v == "true"
+	}
+
+	return !isProduction()
+}
+
+// loadDotenv adds the variables from filenames, .env by default, to the
+// environment unless dotenvEnabled says otherwise. Variables that are already
+// set keep their value. Reading .env in production is logged so operators
+// know about the extra configuration source.
+func loadDotenv(filenames ...string) error {
+	if !dotenvEnabled() {
+		return nil
+	}
+
+	if err := godotenv.Load(filenames...); err != nil {
+		return err
+	}
+
+	if isProduction() {
+		log.Print("WARNING: loaded configuration from .env while APP_ENV=production, set LOAD_DOTENV=false to ignore it")
+	}
+	return nil
+}
+
+// Load builds the configuration from the environment, reporting every invalid setting.
+func Load() (*models.Config, error) {
+	return load(os.LookupEnv)
+}
+
+// load builds the configuration from the variables lookup returns.
+func load(lookup func(key string) (string, bool)) (*models.Config, error) {
+	l := loader{lookup: lookup}
+
+	cfg := &models.Config{
+		Port:           l.getEnv("PORT", "3000"),
+		GeminiAPIKeys:  l.getEnvList("GEMINI_API_KEYS", l.getEnvList("GEMINI_API_KEY", nil)),
+		GeminiModel:    l.getEnv("GEMINI_MODEL", "gemini-flash-latest"),
+		GeminiEndpoint: l.getenv("GEMINI_ENDPOINT"),
+		Origin:         l.getenv("ORIGIN"),
+		ReverseProxyIP: l.getenv("REVERSE_PROXY_IP"),
+		BasicAuthUser:  l.getenv("BASIC_AUTH_USER"),
+		BasicAuthPass:  l.getenv("BASIC_AUTH_PASS"),
+		StaticDir:      l.getEnv("STATIC_DIR", "./static"),
+		StaticHosts:    l.getEnvMap("STATIC_HOSTS"),
+		MinMessageMode: l.getEnv("MIN_MESSAGE_MODE", "reject"),
+
+		DefaultPreset: l.getEnv("DEFAULT_PRESET", "balanced"),
+
+		SessionStore:          l.getEnv("SESSION_STORE", "memory"),
+		SessionCookieName:     l.getenv("SESSION_COOKIE_NAME"),
+		SessionCookieDomain:   l.getenv("SESSION_COOKIE_DOMAIN"),
+		SessionCookieSameSite: l.getEnv("SESSION_COOKIE_SAMESITE", fiber.CookieSameSiteLaxMode),
+		SessionAffinityHeader: l.getenv("SESSION_AFFINITY_HEADER"),
+		FingerprintHeader:     l.getenv("FINGERPRINT_HEADER"),
+		TimezoneHeader:        l.getEnv("TIMEZONE_HEADER", "X-Timezone"),
+
+		EmergencyKeywords: l.getEnvList("EMERGENCY_KEYWORDS", defaultEmergencyKeywords),
+		EmergencyMessage:  l.getEnv("EMERGENCY_MESSAGE", defaultEmergencyMessage),
+
+		DeviceBrands: l.getEnvList("DEVICE_BRANDS", defaultDeviceBrands),
+
+		HandoffModel: l.getenv("HANDOFF_MODEL"),
+
+		LogFormat:       l.getEnv("LOG_FORMAT", logger.DefaultFormat),
+		LogRedactFields: l.getEnvList("LOG_REDACT_FIELDS", defaultLogRedactFields),
+
+		LowQualityPhrases: l.getEnvList("LOW_QUALITY_PHRASES", defaultLowQualityPhrases),
+
+		OTelEndpoint: l.getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+
+		DeadLetterDir: l.getenv("DEAD_LETTER_DIR"),
+
+		FirstResponseDisclaimer: l.getenv("FIRST_RESPONSE_DISCLAIMER"),
+		ReadingLevel:            l.getenv("READING_LEVEL"),
+
+		ExportWebhookURL: l.getenv("EXPORT_WEBHOOK_URL"),
+
+		StartupMessage: l.getEnv("STARTUP_MESSAGE", defaultStartupMessage),
+
+		TLSCertFile: l.getenv("TLS_CERT_FILE"),
+		TLSKeyFile:  l.getenv("TLS_KEY_FILE"),
+
+		RequiredHeader:      l.getenv("REQUIRED_HEADER"),
+		RequiredHeaderValue: l.getenv("REQUIRED_HEADER_VALUE"),
+	}
+
+	cfg.ModelCheck = l.getenv("MODEL_CHECK") != "false"
+	cfg.TLSMinVersion = l.tlsVersion("TLS_MIN_VERSION", tls.VersionTLS12)
+	cfg.TLSCipherSuites = l.cipherSuites("TLS_CIPHER_SUITES")
+	cfg.EnforceHTTPS = l.getenv("ENFORCE_HTTPS") == "true"
+	cfg.EnableMonitoring = l.getenv("ENABLE_MONITORING") == "true"
+	cfg.EnableDebug = l.getenv("ENABLE_DEBUG_ENDPOINTS") == "true"
+	cfg.MinMessageLength = l.getEnvInt("MIN_MESSAGE_LENGTH", 0)
+	cfg.SummaryInterval = l.getEnvInt("SUMMARY_INTERVAL", 0)
+	// By default a summarised conversation keeps two summary intervals of turns
+	cfg.HistoryTurns = l.getEnvInt("HISTORY_TURNS", 2*cfg.SummaryInterval)
+	cfg.ResetSummary = l.getenv("RESET_SUMMARY") == "true"
+	cfg.ResetSummaryMinTurns = l.getEnvInt("RESET_SUMMARY_MIN_TURNS", 3)
+	cfg.SessionCookieHTTPOnly = l.getEnv("SESSION_COOKIE_HTTPONLY", "true") == "true"
+	cfg.SessionCookieSecure = l.getEnv("SESSION_COOKIE_SECURE", strconv.FormatBool(cfg.EnforceHTTPS)) == "true"
+	cfg.PromptProfiles = l.loadProfiles(l.getenv("PROMPT_PROFILES_FILE"))
+	cfg.Presets = l.loadPresets(l.getenv("PRESETS_FILE"))
+	cfg.FingerprintMergeWindow = l.getEnvDuration("FINGERPRINT_MERGE_WINDOW", 30*time.Minute)
+	cfg.ProfileInResponse = l.getenv("PROFILE_IN_RESPONSE") == "true"
+	cfg.TurnIDInResponse = l.getenv("TURN_ID_IN_RESPONSE") == "true"
+	cfg.EmergencyDetection = l.getEnv("EMERGENCY_DETECTION", "true") == "true"
+	cfg.DeviceDetection = l.getenv("DEVICE_DETECTION") == "true"
+	cfg.HandoffAfterTurns = l.getEnvInt("HANDOFF_AFTER_TURNS", 0)
+	cfg.HandoffAfterTokens = l.getEnvInt("HANDOFF_AFTER_TOKENS", 0)
+	cfg.RedactPatterns = l.loadPatterns(l.getenv("REDACT_PATTERNS_FILE"))
+	cfg.LogStreamSize = l.getEnvInt("LOG_STREAM_SIZE", 500)
+	cfg.ShutdownTimeout = l.getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
+	cfg.GeminiKeyCooldown = l.getEnvDuration("GEMINI_KEY_COOLDOWN", time.Minute)
+	cfg.DailyRequestBudget = l.getEnvInt("DAILY_REQUEST_BUDGET", 0)
+	cfg.DailyTokenBudget = l.getEnvInt("DAILY_TOKEN_BUDGET", 0)
+	cfg.LowQualityRetry = l.getenv("LOW_QUALITY_RETRY") == "true"
+	cfg.LowQualityMinQuestion = l.getEnvInt("LOW_QUALITY_MIN_QUESTION", 30)
+	cfg.LowQualityMinAnswer = l.getEnvInt("LOW_QUALITY_MIN_ANSWER", 80)
+	cfg.MaxResponseWords = l.getEnvInt("MAX_RESPONSE_WORDS", 0)
+	cfg.RephraseLimit = l.getEnvInt("REPHRASE_LIMIT", 3)
+	cfg.DatetimeContext = l.getenv("DATETIME_CONTEXT") == "true"
+	cfg.DeadLetterRetention = l.getEnvDuration("DEAD_LETTER_RETENTION", 7*24*time.Hour)
+	cfg.DeadLetterMinStatus = l.getEnvInt("DEAD_LETTER_MIN_STATUS", fiber.StatusInternalServerError)
+	cfg.StaticFallback = l.getenv("STATIC_FALLBACK") != "false"
+	cfg.SessionIdleTimeout = l.getEnvDuration("SESSION_IDLE_TIMEOUT", 30*time.Minute)
+	cfg.SessionTierTimeouts = l.getEnvDurationMap("SESSION_TIER_TIMEOUTS")
+	cfg.VerbosityBriefMaxTokens = l.getEnvInt("VERBOSITY_BRIEF_MAX_TOKENS", 256)
+	cfg.VerbosityDetailedMaxTokens = l.getEnvInt("VERBOSITY_DETAILED_MAX_TOKENS", 4096)
+	cfg.EmptyResponseRetry = l.getenv("EMPTY_RESPONSE_RETRY") != "false"
+	cfg.RateLimitMax = l.getEnvInt("RATE_LIMIT_MAX", 1000)
+	cfg.RateLimitWindow = l.getEnvDuration("RATE_LIMIT_WINDOW", 30*time.Minute)
+	cfg.SessionRateLimitMax = l.getEnvInt("SESSION_RATE_LIMIT_MAX", 0)
+	cfg.SessionRateLimitWindow = l.getEnvDuration("SESSION_RATE_LIMIT_WINDOW", cfg.RateLimitWindow)
+	cfg.GlobalRateLimit = l.getEnvFloat("GLOBAL_RATE_LIMIT", 0)
+	cfg.GlobalRateBurst = l.getEnvInt("GLOBAL_RATE_BURST", max(1, int(math.Ceil(cfg.GlobalRateLimit))))
+	cfg.StartupWarmup = l.getenv("STARTUP_WARMUP") == "true"
+	cfg.TokenCacheSize = l.getEnvInt("TOKEN_CACHE_SIZE", 1000)
+	cfg.AdaptiveCompression = l.getenv("ADAPTIVE_COMPRESSION") == "true"
+	cfg.CompressionFastLoad = l.getEnvFloat("COMPRESSION_FAST_LOAD", 0.75)
+	cfg.CompressionOffLoad = l.getEnvFloat("COMPRESSION_OFF_LOAD", 1.5)
+	cfg.Examples = l.loadExamples(l.getenv("EXAMPLES_FILE"), l.getEnvInt("MAX_EXAMPLES", 10), l.getEnvInt("MAX_EXAMPLE_CHARS", 1000))
+
+	if cfg.SessionCookieName != "" {
+		cfg.LogRedactFields = append(cfg.LogRedactFields, cfg.SessionCookieName)
+	}
+	if cfg.RequiredHeader != "" {
+		cfg.LogRedactFields = append(cfg.LogRedactFields, cfg.RequiredHeader)
+	}
+
+	l.validate(cfg)
+
+	return cfg, l.err()
+}
+
+// validate reports the settings in cfg that are out of range or conflict with
+// each other.
+func (l *loader) validate(cfg *models.Config) {
+	if len(cfg.GeminiAPIKeys) == 0 {
+		l.fail("GEMINI_API_KEY or GEMINI_API_KEYS is required")
+	}
+
+	if cfg.GeminiEndpoint != "" {
+		u, err := url.Parse(cfg.GeminiEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			l.fail("GEMINI_ENDPOINT must be an absolute http(s) URL, got %q", cfg.GeminiEndpoint)
+		}
+	}
+
+	if cfg.HistoryTurns < 0 {
+		l.fail("HISTORY_TURNS must not be negative")
+	}
+	if cfg.HistoryTurns > 0 && cfg.HistoryTurns < cfg.SummaryInterval {
+		l.fail("HISTORY_TURNS must be at least SUMMARY_INTERVAL, or turns are dropped before they are summarised")
+	}
+
+	if cfg.ResetSummary && cfg.ResetSummaryMinTurns < 1 {
+		l.fail("RESET_SUMMARY_MIN_TURNS must be at least 1")
+	}
+
+	if cfg.RateLimitMax < 1 {
+		l.fail("RATE_LIMIT_MAX must be at least 1")
+	}
+
+	if cfg.GlobalRateLimit > 0 && cfg.GlobalRateBurst < 1 {
+		l.fail("GLOBAL_RATE_BURST must be at least 1 when GLOBAL_RATE_LIMIT is set")
+	}
+
+	if cfg.LogStreamSize < 1 {
+		l.fail("LOG_STREAM_SIZE must be at least 1")
+	}
+
+	if cfg.DeadLetterMinStatus < 400 || cfg.DeadLetterMinStatus > 599 {
+		l.fail("DEAD_LETTER_MIN_STATUS must be an HTTP error status between 400 and 599")
+	}
+	if cfg.DeadLetterRetention <= 0 {
+		l.fail("DEAD_LETTER_RETENTION must be positive")
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		l.fail("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	if cfg.ExportWebhookURL != "" {
+		u, err := url.Parse(cfg.ExportWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			l.fail("EXPORT_WEBHOOK_URL must be an absolute http(s) URL")
 		}
+	}
+
+	if _, ok := cfg.Presets[cfg.DefaultPreset]; !ok {
+		l.fail("DEFAULT_PRESET %q is not a known preset", cfg.DefaultPreset)
+	}
+	for name, profile := range cfg.PromptProfiles {
+		if _, ok := cfg.Presets[profile.Preset]; profile.Preset != "" && !ok {
+			l.fail("prompt profile %q uses unknown preset %q", name, profile.Preset)
+		}
+	}
+
+	if cfg.SessionStore != "memory" {
+		l.fail("SESSION_STORE must be \"memory\", got %q", cfg.SessionStore)
+	}
+
+	if cfg.MinMessageMode != "reject" && cfg.MinMessageMode != "clarify" {
+		l.fail("MIN_MESSAGE_MODE must be \"reject\" or \"clarify\", got %q", cfg.MinMessageMode)
+	}
+
+	if cfg.AdaptiveCompression && cfg.CompressionOffLoad <= cfg.CompressionFastLoad {
+		l.fail("COMPRESSION_OFF_LOAD must be greater than COMPRESSION_FAST_LOAD")
+	}
 
-		Config.EnforceHTTPS = os.Getenv("ENFORCE_HTTPS") == "true"
-		Config.EnableMonitoring = os.Getenv("ENABLE_MONITORING") == "true"
-		Config.EnableDebug = os.Getenv("ENABLE_DEBUG_ENDPOINTS") == "true"
+	if cfg.RequiredHeader != "" && cfg.RequiredHeaderValue == "" {
+		l.fail("REQUIRED_HEADER requires REQUIRED_HEADER_VALUE")
+	}
 
-		if Config.GeminiAPIKey == "" {
-			log.Fatal("GEMINI_API_KEY is required")
+	if cfg.SessionRateLimitMax > 0 && cfg.SessionCookieName == "" {
+		l.fail("SESSION_RATE_LIMIT_MAX requires SESSION_COOKIE_NAME")
+	}
+
+	if cfg.HandoffModel != "" && cfg.HandoffAfterTurns <= 0 && cfg.HandoffAfterTokens <= 0 {
+		l.fail("HANDOFF_MODEL requires HANDOFF_AFTER_TURNS or HANDOFF_AFTER_TOKENS")
+	}
+
+	switch cfg.SessionCookieSameSite {
+	case fiber.CookieSameSiteStrictMode, fiber.CookieSameSiteLaxMode:
+	case fiber.CookieSameSiteNoneMode:
+		if !cfg.SessionCookieSecure {
+			l.fail("SESSION_COOKIE_SAMESITE=None requires a Secure cookie")
 		}
-	})
+	default:
+		l.fail("SESSION_COOKIE_SAMESITE must be Strict, Lax or None, got %q", cfg.SessionCookieSameSite)
+	}
+}
+
+// loader reads variables through lookup and collects configuration errors so
+// they can all be reported at once.
+type loader struct {
+	lookup func(key string) (string, bool)
+	errs   []error
+}
+
+func (l *loader) getenv(key string) string {
+	value, _ := l.lookup(key)
+	return value
 }
 
-func getEnv(key, fallback string) string {
-	return cmp.Or(os.Getenv(key), fallback)
+func (l *loader) fail(format string, args ...any) {
+	l.errs = append(l.errs, fmt.Errorf(format, args...))
+}
+
+func (l *loader) err() error {
+	return errors.Join(l.errs...)
+}
+
+func (l *loader) getEnv(key, fallback string) string {
+	return cmp.Or(l.getenv(key), fallback)
+}
+
+func (l *loader) getEnvInt(key string, fallback int) int {
+	raw := l.getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		l.fail("%s must be an integer: %v", key, err)
+		return fallback
+	}
+
+	return value
+}
+
+func (l *loader) getEnvFloat(key string, fallback float64) float64 {
+	raw := l.getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		l.fail("%s must be a number: %v", key, err)
+		return fallback
+	}
+
+	return value
+}
+
+func (l *loader) getEnvDuration(key string, fallback time.Duration) time.Duration {
+	raw := l.getenv(key)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		l.fail("%s must be a duration such as 30s or 5m: %v", key, err)
+		return fallback
+	}
+
+	return value
+}
+
+// getEnvDurationMap parses tier=duration pairs such as "premium=4h,device=2h".
+func (l *loader) getEnvDurationMap(key string) map[string]time.Duration {
+	result := make(map[string]time.Duration)
+
+	for name, raw := range l.getEnvMap(key) {
+		value, err := time.ParseDuration(raw)
+		if err != nil || value <= 0 {
+			l.fail("%s: %s must be a positive duration such as 2h, got %q", key, name, raw)
+			continue
+		}
+		result[name] = value
+	}
+
+	return result
+}
+
+// tlsVersion parses a minimum TLS version, refusing anything older than 1.2.
+func (l *loader) tlsVersion(key string, fallback uint16) uint16 {
+	switch raw := l.getenv(key); raw {
+	case "":
+		return fallback
+	case "1.2":
+		return tls.VersionTLS12
+	case "1.3":
+		return tls.VersionTLS13
+	default:
+		l.fail("%s must be 1.2 or 1.3, got %q", key, raw)
+		return fallback
+	}
+}
+
+// cipherSuites parses a comma-separated list of TLS 1.2 cipher suite names,
+// refusing the ones Go considers insecure. TLS 1.3 suites are not configurable.
+func (l *loader) cipherSuites(key string) []uint16 {
+	var suites []uint16
+
+	for _, name := range l.getEnvList(key, nil) {
+		i := slices.IndexFunc(tls.CipherSuites(), func(s *tls.CipherSuite) bool { return s.Name == name })
+		if i < 0 {
+			if slices.ContainsFunc(tls.InsecureCipherSuites(), func(s *tls.CipherSuite) bool { return s.Name == name }) {
+				l.fail("%s: cipher suite %s is insecure", key, name)
+			} else {
+				l.fail("%s: unknown cipher suite %s", key, name)
+			}
+			continue
+		}
+		suites = append(suites, tls.CipherSuites()[i].ID)
+	}
+
+	return suites
+}
+
+// getEnvList parses a comma-separated list, returning fallback when the variable is unset.
+func (l *loader) getEnvList(key string, fallback []string) []string {
+	raw, ok := l.lookup(key)
+	if !ok {
+		return fallback
+	}
+
+	var result []string
+	for item := range strings.SplitSeq(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// getEnvMap parses a comma-separated list of key=value pairs,
+// e.g. "app1.example.com=./static/app1,app2.example.com=./static/app2".
+func (l *loader) getEnvMap(key string) map[string]string {
+	result := make(map[string]string)
+
+	for pair := range strings.SplitSeq(l.getenv(key), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" || v == "" {
+			continue
+		}
+		result[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+
+	return result
+}
+
+// loadProfiles reads a JSON object mapping profile names to their description and prompt.
+func (l *loader) loadProfiles(path string) map[string]models.PromptProfile {
+	profiles := make(map[string]models.PromptProfile)
+	if path == "" {
+		return profiles
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.fail("failed to read PROMPT_PROFILES_FILE: %v", err)
+		return profiles
+	}
+
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		l.fail("failed to parse PROMPT_PROFILES_FILE: %v", err)
+		return profiles
+	}
+
+	for name, profile := range profiles {
+		if strings.TrimSpace(profile.Prompt) == "" {
+			l.fail("prompt profile %q has an empty prompt", name)
+		}
+		if err := profile.Validate(); err != nil {
+			l.fail("prompt profile %q: %v", name, err)
+		}
+	}
+
+	return profiles
+}
+
+// loadPresets reads a JSON object mapping preset names to generation
+// parameters. File entries are added to the built-in presets or replace them.
+func (l *loader) loadPresets(path string) map[string]models.GenerationParams {
+	presets := maps.Clone(models.DefaultPresets)
+	if path == "" {
+		return presets
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.fail("failed to read PRESETS_FILE: %v", err)
+		return presets
+	}
+
+	var custom map[string]models.GenerationParams
+	if err := json.Unmarshal(data, &custom); err != nil {
+		l.fail("failed to parse PRESETS_FILE: %v", err)
+		return presets
+	}
+
+	for name, params := range custom {
+		if err := params.Validate(); err != nil {
+			l.fail("preset %q: %v", name, err)
+		}
+		presets[name] = params
+	}
+
+	return presets
+}
+
+// loadExamples reads a JSON array of question/answer pairs, refusing files
+// with more than maxCount examples or examples longer than maxChars.
+func (l *loader) loadExamples(path string, maxCount, maxChars int) []models.Example {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.fail("failed to read EXAMPLES_FILE: %v", err)
+		return nil
+	}
+
+	var examples []models.Example
+	if err := json.Unmarshal(data, &examples); err != nil {
+		l.fail("failed to parse EXAMPLES_FILE: %v", err)
+		return nil
+	}
+
+	if len(examples) > maxCount {
+		l.fail("EXAMPLES_FILE has %d examples, the limit is %d", len(examples), maxCount)
+		return nil
+	}
+
+	for i, ex := range examples {
+		if ex.Question == "" || ex.Answer == "" {
+			l.fail("example %d needs both a question and an answer", i+1)
+		}
+		if n := utf8.RuneCountInString(ex.Question + ex.Answer); n > maxChars {
+			l.fail("example %d is %d characters long, the limit is %d", i+1, n, maxChars)
+		}
+	}
+
+	return examples
+}
+
+// loadPatterns reads one regular expression per line, skipping blank lines and # comments.
+func (l *loader) loadPatterns(path string) []*regexp.Regexp {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		l.fail("failed to read REDACT_PATTERNS_FILE: %v", err)
+		return nil
+	}
+
+	var patterns []*regexp.Regexp
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := regexp.Compile(line)
+		if err != nil {
+			l.fail("invalid redaction pattern %q: %v", line, err)
+			continue
+		}
+		patterns = append(patterns, p)
+	}
+
+	return patterns
 }
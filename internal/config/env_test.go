@@ -0,0 +1,150 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// unsetenv clears key for the test and restores it afterwards.
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func writeDotenv(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// lookupMap serves vars in place of the process environment, for load
+func lookupMap(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+func TestLoadDotenv(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		unsetenv(t, "LOAD_DOTENV")
+		unsetenv(t, "APP_ENV")
+
+		if err := loadDotenv(filepath.Join(t.TempDir(), ".env")); err == nil {
+			t.Error("loadDotenv() succeeded for a missing file, want an error")
+		}
+	})
+
+	t.Run("present file", func(t *testing.T) {
+		unsetenv(t, "LOAD_DOTENV")
+		unsetenv(t, "APP_ENV")
+		unsetenv(t, "DOTENV_TEST_VALUE")
+
+		if err := loadDotenv(writeDotenv(t, "DOTENV_TEST_VALUE=from-file\n")); err != nil {
+			t.Fatal(err)
+		}
+		if got := os.Getenv("DOTENV_TEST_VALUE"); got != "from-file" {
+			t.Errorf("DOTENV_TEST_VALUE = %q, want %q", got, "from-file")
+		}
+	})
+
+	t.Run("environment wins over file", func(t *testing.T) {
+		unsetenv(t, "LOAD_DOTENV")
+		unsetenv(t, "APP_ENV")
+		t.Setenv("DOTENV_TEST_VALUE", "from-env")
+
+		if err := loadDotenv(writeDotenv(t, "DOTENV_TEST_VALUE=from-file\n")); err != nil {
+			t.Fatal(err)
+		}
+		if got := os.Getenv("DOTENV_TEST_VALUE"); got != "from-env" {
+			t.Errorf("DOTENV_TEST_VALUE = %q, want %q", got, "from-env")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		unsetenv(t, "APP_ENV")
+		unsetenv(t, "DOTENV_TEST_VALUE")
+		t.Setenv("LOAD_DOTENV", "false")
+
+		if err := loadDotenv(filepath.Join(t.TempDir(), ".env")); err != nil {
+			t.Errorf("loadDotenv() = %v with LOAD_DOTENV=false, want nil", err)
+		}
+	})
+
+	t.Run("production skips by default", func(t *testing.T) {
+		unsetenv(t, "LOAD_DOTENV")
+		unsetenv(t, "DOTENV_TEST_VALUE")
+		t.Setenv("APP_ENV", "production")
+
+		if err := loadDotenv(writeDotenv(t, "DOTENV_TEST_VALUE=from-file\n")); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := os.LookupEnv("DOTENV_TEST_VALUE"); ok {
+			t.Errorf("DOTENV_TEST_VALUE = %q in production, want it unset", got)
+		}
+	})
+
+	t.Run("production opt in", func(t *testing.T) {
+		unsetenv(t, "DOTENV_TEST_VALUE")
+		t.Setenv("APP_ENV", "production")
+		t.Setenv("LOAD_DOTENV", "true")
+
+		if err := loadDotenv(writeDotenv(t, "DOTENV_TEST_VALUE=from-file\n")); err != nil {
+			t.Fatal(err)
+		}
+		if got := os.Getenv("DOTENV_TEST_VALUE"); got != "from-file" {
+			t.Errorf("DOTENV_TEST_VALUE = %q, want %q", got, "from-file")
+		}
+	})
+}
+
+func TestLoadRedactsSecrets(t *testing.T) {
+	cfg, err := load(lookupMap(map[string]string{
+		"GEMINI_API_KEY":        "test-key",
+		"SESSION_COOKIE_NAME":   "sid",
+		"REQUIRED_HEADER":       "X-Edge-Secret",
+		"REQUIRED_HEADER_VALUE": "s3cret",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"Authorization", "sid", "X-Edge-Secret"} {
+		if !slices.Contains(cfg.LogRedactFields, field) {
+			t.Errorf("LogRedactFields = %v, missing %q", cfg.LogRedactFields, field)
+		}
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		vars  map[string]string
+		valid bool
+	}{
+		{"defaults", nil, true},
+		{"reset summary", map[string]string{"RESET_SUMMARY": "true"}, true},
+		{"global rate limit", map[string]string{"GLOBAL_RATE_LIMIT": "0.5"}, true},
+		{"global rate limit without burst", map[string]string{"GLOBAL_RATE_LIMIT": "5", "GLOBAL_RATE_BURST": "0"}, false},
+		{"burst without global rate limit", map[string]string{"GLOBAL_RATE_BURST": "0"}, true},
+		{"reset summary without turns", map[string]string{"RESET_SUMMARY": "true", "RESET_SUMMARY_MIN_TURNS": "0"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]string{"GEMINI_API_KEY": "test-key"}
+			maps.Copy(vars, tt.vars)
+
+			if _, err := load(lookupMap(vars)); (err == nil) != tt.valid {
+				t.Errorf("load() error = %v, want valid = %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/joho/godotenv"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+// hotReloadable lists the settings a reload applies. Handlers and middleware
+// read them from the current snapshot on every request, and the services
+// built from the prompt, preset, keyword, brand and budget settings are
+// rebuilt by the reconfigure functions passed to Reload. Everything else is
+// baked into clients, listeners or middleware when the server starts.
+var hotReloadable = []string{
+	"MinMessageLength",
+	"MinMessageMode",
+	"SummaryInterval",
+	"HistoryTurns",
+	"ResetSummary",
+	"ResetSummaryMinTurns",
+	"SessionAffinityHeader",
+	"SessionIdleTimeout",
+	"SessionTierTimeouts",
+	"DatetimeContext",
+	"TimezoneHeader",
+	"FingerprintHeader",
+	"FingerprintMergeWindow",
+	"PromptProfiles",
+	"Presets",
+	"DefaultPreset",
+	"ProfileInResponse",
+	"TurnIDInResponse",
+	"EmergencyDetection",
+	"EmergencyKeywords",
+	"EmergencyMessage",
+	"DeviceDetection",
+	"DeviceBrands",
+	"HandoffAfterTurns",
+	"HandoffAfterTokens",
+	"FirstResponseDisclaimer",
+	"ReadingLevel",
+	"Examples",
+	"MaxResponseWords",
+	"RephraseLimit",
+	"VerbosityBriefMaxTokens",
+	"VerbosityDetailedMaxTokens",
+	"DailyRequestBudget",
+	"DailyTokenBudget",
+	"LowQualityRetry",
+	"LowQualityMinQuestion",
+	"LowQualityMinAnswer",
+	"LowQualityPhrases",
+	"EmptyResponseRetry",
+	"RateLimitMax",
+	"RateLimitWindow",
+	"SessionRateLimitMax",
+	"SessionRateLimitWindow",
+	"GlobalRateLimit",
+	"GlobalRateBurst",
+	"StartupMessage",
+}
+
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// reloadMu keeps two reloads from building on the same snapshot.
+var reloadMu sync.Mutex
+
+// readDotenv returns the variables in .env without applying them, or none
+// when dotenvEnabled says .env is not read.
+func readDotenv() (map[string]string, error) {
+	if !dotenvEnabled() {
+		return nil, nil
+	}
+
+	return godotenv.Read()
+}
+
+// Reload re-reads the .env file, whose values replace the ones read at
+// startup, and builds a new configuration. Changed hot-reloadable settings
+// take their new value. Other changed settings keep the running value and are
+// reported as needing a restart. Each reconfigure function rebuilds the
+// services derived from the configuration before the new snapshot replaces the
+// one in live. Requests that already loaded the old snapshot finish with it.
+// If the new configuration is invalid, alone or together with the settings it
+// keeps, nothing is applied, not even to the process environment.
+func Reload(live *atomic.Pointer[models.Config], reconfigure ...func(*models.Config)) (*ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	file, err := readDotenv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	next, err := load(func(key string) (string, bool) {
+		if value, ok := file[key]; ok {
+			return value, true
+		}
+		return os.LookupEnv(key)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+
+	// next isn't shared yet, so its fields can still be set
+	current, updated := reflect.ValueOf(live.Load()).Elem(), reflect.ValueOf(next).Elem()
+	for i := range current.NumField() {
+		name := current.Type().Field(i).Name
+		if reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			continue
+		}
+
+		if !slices.Contains(hotReloadable, name) {
+			updated.Field(i).Set(current.Field(i))
+			result.RestartRequired = append(result.RestartRequired, name)
+			continue
+		}
+
+		result.Applied = append(result.Applied, name)
+	}
+
+	// The kept settings may not fit the new ones, e.g. a session rate limit
+	// without the session cookie it needs, so the merged snapshot is checked again
+	var l loader
+	if l.validate(next); l.err() != nil {
+		return nil, fmt.Errorf("the new settings conflict with ones that need a restart: %w", l.err())
+	}
+
+	for key, value := range file {
+		if err := os.Setenv(key, value); err != nil {
+			log.Printf("failed to set %s from .env: %v", key, err)
+		}
+	}
+
+	for _, fn := range reconfigure {
+		fn(next)
+	}
+	live.Store(next)
+
+	log.Printf("configuration reloaded, applied: %v, restart required: %v", result.Applied, result.RestartRequired)
+
+	return result, nil
+}
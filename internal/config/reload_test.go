@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+// startReload loads a configuration from the environment alone and moves the
+// test into a directory whose .env holds content, for Reload to read.
+func startReload(t *testing.T, content string) *atomic.Pointer[models.Config] {
+	t.Helper()
+
+	unsetenv(t, "APP_ENV")
+	t.Setenv("LOAD_DOTENV", "true")
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	for _, key := range []string{"PORT", "MIN_MESSAGE_LENGTH", "MIN_MESSAGE_MODE", "DOTENV_TEST_VALUE", "SESSION_COOKIE_NAME", "SESSION_RATE_LIMIT_MAX"} {
+		unsetenv(t, key)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := &atomic.Pointer[models.Config]{}
+	live.Store(cfg)
+
+	path := writeDotenv(t, content)
+	t.Chdir(filepath.Dir(path))
+
+	return live
+}
+
+func TestReload(t *testing.T) {
+	live := startReload(t, "MIN_MESSAGE_LENGTH=5\nPORT=4000\n")
+	before := live.Load()
+
+	var reconfigured *models.Config
+	result, err := Reload(live, func(cfg *models.Config) { reconfigured = cfg })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := live.Load()
+	if after == before {
+		t.Fatal("Reload changed the running snapshot instead of replacing it")
+	}
+	if reconfigured != after {
+		t.Error("reconfigure was not called with the new snapshot")
+	}
+
+	if !slices.Contains(result.Applied, "MinMessageLength") || after.MinMessageLength != 5 {
+		t.Errorf("MinMessageLength = %d, applied %v; want 5 applied", after.MinMessageLength, result.Applied)
+	}
+	if before.MinMessageLength != 0 {
+		t.Errorf("old snapshot MinMessageLength = %d, want it unchanged", before.MinMessageLength)
+	}
+
+	if !slices.Contains(result.RestartRequired, "Port") || after.Port != before.Port {
+		t.Errorf("Port = %q, restart required %v; want %q kept and reported", after.Port, result.RestartRequired, before.Port)
+	}
+
+	if got := os.Getenv("MIN_MESSAGE_LENGTH"); got != "5" {
+		t.Errorf("MIN_MESSAGE_LENGTH = %q in the environment, want %q", got, "5")
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	live := startReload(t, "MIN_MESSAGE_MODE=bogus\nDOTENV_TEST_VALUE=from-file\n")
+	before := live.Load()
+
+	called := false
+	if _, err := Reload(live, func(*models.Config) { called = true }); err == nil {
+		t.Fatal("Reload accepted an invalid MIN_MESSAGE_MODE")
+	}
+
+	if live.Load() != before || called {
+		t.Error("a rejected reload replaced the snapshot or reconfigured services")
+	}
+	if got, ok := os.LookupEnv("DOTENV_TEST_VALUE"); ok {
+		t.Errorf("DOTENV_TEST_VALUE = %q after a rejected reload, want it unset", got)
+	}
+}
+
+func TestReloadConflictsWithKeptSetting(t *testing.T) {
+	// The cookie name needs a restart, so the running snapshot keeps it empty
+	live := startReload(t, "SESSION_COOKIE_NAME=sid\nSESSION_RATE_LIMIT_MAX=5\n")
+	before := live.Load()
+
+	called := false
+	if _, err := Reload(live, func(*models.Config) { called = true }); err == nil {
+		t.Fatal("Reload applied SESSION_RATE_LIMIT_MAX without the session cookie it needs")
+	}
+
+	if live.Load() != before || called {
+		t.Error("a rejected reload replaced the snapshot or reconfigured services")
+	}
+	if got, ok := os.LookupEnv("SESSION_RATE_LIMIT_MAX"); ok {
+		t.Errorf("SESSION_RATE_LIMIT_MAX = %q after a rejected reload, want it unset", got)
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/services"
+)
+
+type BudgetHandler struct {
+	Budget *services.BudgetService
+}
+
+func NewBudgetHandler(b *services.BudgetService) *BudgetHandler {
+	return &BudgetHandler{Budget: b}
+}
+
+func (h *BudgetHandler) Remaining(c fiber.Ctx) error {
+	return c.JSON(h.Budget.Remaining(c.IP()))
+}
@@ -1,40 +1,550 @@
 package handlers
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v3"
+	"github.com/google/generative-ai-go/genai"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/lavish440/Home-Security-Chatbot/internal/middleware"
 	"github.com/lavish440/Home-Security-Chatbot/internal/models"
 	"github.com/lavish440/Home-Security-Chatbot/internal/services"
 )
 
+const instrumentationName = "github.com/lavish440/Home-Security-Chatbot/internal/handlers"
+
+// minFingerprintLength keeps short, guessable fingerprints from merging sessions
+const minFingerprintLength = 16
+
+// turnWaitTimeout bounds how long a message waits for the previous one on the
+// same session to be answered
+const turnWaitTimeout = 30 * time.Second
+
+const reasonTooShort = "message is too short"
+
+const clarifyPrompt = "Could you tell me a bit more? I can help with questions about home security systems, cameras, alarms, sensors and similar topics."
+
 type ChatHandler struct {
 	AI       *services.AIService
 	Sessions *services.SessionService
+	Config   *atomic.Pointer[models.Config]
+	Redactor *services.Redactor
+	Budget   *services.BudgetService
+	Stats    *services.StatsService
+
+	// Detectors are rebuilt from the keyword and brand lists on Reconfigure
+	emergency atomic.Pointer[services.EmergencyDetector]
+	devices   atomic.Pointer[services.DeviceDetector]
+
+	tracer   trace.Tracer
+	requests metric.Int64Counter
+	latency  metric.Float64Histogram
 }
 
-func NewChatHandler(ai *services.AIService, sessions *services.SessionService) *ChatHandler {
-	return &ChatHandler{AI: ai, Sessions: sessions}
+func NewChatHandler(ai *services.AIService, sessions *services.SessionService, budget *services.BudgetService, stats *services.StatsService, cfg *atomic.Pointer[models.Config]) *ChatHandler {
+	meter := otel.Meter(instrumentationName)
+
+	// Instrument creation only fails on invalid names, in which case a no-op instrument is returned
+	requests, _ := meter.Int64Counter("chat.requests", metric.WithDescription("Chat requests by outcome"))
+	latency, _ := meter.Float64Histogram("gemini.latency", metric.WithUnit("ms"), metric.WithDescription("Gemini call latency"))
+
+	h := &ChatHandler{
+		AI:       ai,
+		Sessions: sessions,
+		Budget:   budget,
+		Stats:    stats,
+		Config:   cfg,
+		Redactor: services.NewRedactor(cfg.Load().RedactPatterns),
+
+		tracer:   otel.Tracer(instrumentationName),
+		requests: requests,
+		latency:  latency,
+	}
+	h.Reconfigure(cfg.Load())
+
+	return h
+}
+
+// Reconfigure rebuilds the emergency and device detectors from cfg. A reload
+// calls it before publishing cfg, so a request that sees the new settings
+// also gets the new detectors.
+func (h *ChatHandler) Reconfigure(cfg *models.Config) {
+	h.emergency.Store(services.NewEmergencyDetector(cfg.EmergencyKeywords))
+	h.devices.Store(services.NewDeviceDetector(cfg.DeviceBrands))
+}
+
+// RequireReady answers 503 with the startup message until the AI service is
+// ready. It runs before every route that calls the model.
+func (h *ChatHandler) RequireReady(c fiber.Ctx) error {
+	if !h.AI.Ready() {
+		resp := h.Config.Load().StartupMessage
+		if safety, ok := middleware.EmergencyMessage(c); ok {
+			resp = safety + "\n\n" + resp
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"response": resp})
+	}
+
+	return c.Next()
+}
+
+// DetectEmergency returns the safety message when msg describes an emergency
+// and detection is enabled, or "". It backs middleware.Emergency.
+func (h *ChatHandler) DetectEmergency(msg string) string {
+	cfg := h.Config.Load()
+	if !cfg.EmergencyDetection || !h.emergency.Load().Detect(msg) {
+		return ""
+	}
+
+	log.Print("emergency detected in chat message, prepending safety message")
+	return cfg.EmergencyMessage
+}
+
+// postProcess redacts and shortens model text before it is returned to the caller.
+func (h *ChatHandler) postProcess(cfg *models.Config, text string) string {
+	if h.Redactor.Enabled() {
+		var redacted bool
+		if text, redacted = h.Redactor.Redact(text); redacted {
+			log.Print("redacted sensitive data from model response")
+		}
+	}
+
+	if limit := cfg.MaxResponseWords; limit > 0 {
+		var truncated bool
+		if text, truncated = services.TruncateWords(text, limit); truncated {
+			log.Printf("truncated model response to %d words", limit)
+		}
+	}
+
+	return text
 }
 
 func (h *ChatHandler) Handle(c fiber.Ctx) error {
+	// One snapshot serves the whole request, a reload takes effect on the next one
+	cfg := h.Config.Load()
+
+	ctx, span := h.tracer.Start(context.Background(), "POST /api/chat")
+	defer span.End()
+
+	outcome := "error"
+	defer func() {
+		h.requests.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", outcome)))
+	}()
+
+	_, parseSpan := h.tracer.Start(ctx, "chat.parse")
+	req, problem := parseChatRequest(c)
+	parseSpan.End()
+	if problem != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": problem})
+	}
+
+	// Flagged by middleware.Emergency, so every early return below can carry the safety message
+	safety, emergency := middleware.EmergencyMessage(c)
+
+	if reasons := h.inputProblems(cfg, req); len(reasons) > 0 {
+		if reasons[0] == reasonTooShort && cfg.MinMessageMode == "clarify" && !emergency {
+			return c.JSON(fiber.Map{"response": clarifyPrompt})
+		}
+		return middleware.Reject(c, fiber.StatusBadRequest, reasons[0])
+	}
+
+	key := h.sessionKey(cfg, c)
+	if key == "" {
+		return middleware.Reject(c, fiber.StatusBadRequest, "Invalid IP")
+	}
+
+	if name := cfg.SessionAffinityHeader; name != "" {
+		c.Set(name, affinityHint(key))
+	}
+
+	if !h.Budget.Allow(c.IP()) {
+		return middleware.Reject(c, fiber.StatusTooManyRequests, "Daily budget exhausted, please try again after the reset.")
+	}
+
+	// Kept to summarise the conversation if a profile switch replaces it
+	prev, _ := h.Sessions.Get(key)
+
+	if name := cfg.FingerprintHeader; name != "" {
+		if fp := c.Get(name); len(fp) >= minFingerprintLength && req.ConversationID != "" {
+			cs := h.Sessions.GetOrCreate(key, req.Profile)
+
+			mergeCtx, cancel := context.WithTimeout(ctx, turnWaitTimeout)
+			merged := h.Sessions.Merge(mergeCtx, key, fp, req.ConversationID, cfg.FingerprintMergeWindow)
+			cancel()
+			if merged {
+				log.Printf("merged an earlier session from the same client into conversation %s", cs.ConversationID)
+			}
+		}
+	}
+
+	// Exchanges on a session run one at a time, so a second message waits for
+	// the first answer instead of both being sent on the same history
+	waitCtx, cancel := context.WithTimeout(ctx, turnWaitTimeout)
+	cs, err := h.Sessions.Claim(waitCtx, key, req.Profile)
+	cancel()
+	if err != nil {
+		return middleware.Reject(c, fiber.StatusTooManyRequests, "Your previous message is still being answered, please try again shortly.")
+	}
+	defer cs.EndTurn()
+
+	// The level sticks to the session until a later request changes it
+	if req.Verbosity != "" {
+		cs.SetVerbosity(req.Verbosity)
+	}
+
+	if cfg.DeviceDetection {
+		detector := h.devices.Load()
+		go func() {
+			if devices := detector.Detect(req.Message); len(devices) > 0 {
+				cs.AddDevices(devices)
+			}
+		}()
+	}
+
+	sendCtx, sendSpan := h.tracer.Start(ctx, "gemini.send")
+	start := time.Now()
+	reply, err := h.AI.Send(sendCtx, cs, req.Message, services.SendOptions{
+		Params:    req.GenerationParams,
+		Preset:    req.Preset,
+		Verbosity: cs.Verbosity(),
+		Now:       h.userTime(cfg, c),
+	})
+	elapsed := time.Since(start)
+	h.latency.Record(ctx, float64(elapsed.Milliseconds()))
+	h.Stats.Record(elapsed, err != nil)
+	if err != nil {
+		sendSpan.RecordError(err)
+		sendSpan.SetStatus(codes.Error, err.Error())
+	}
+	sendSpan.End()
+	if err != nil {
+		if emergency {
+			return c.JSON(fiber.Map{"response": safety})
+		}
+		if errors.Is(err, services.ErrModelUnavailable) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "The assistant's AI model is unavailable right now, please try again later."})
+		}
+		if errors.Is(err, services.ErrEmptyResponse) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "The assistant didn't return an answer, please try rephrasing your question."})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// History keeps the full answer, only what the caller sees is processed
+	resp := h.postProcess(cfg, reply.Text)
+
+	turnRecord := models.Turn{
+		ID:           uuid.NewString(),
+		Timestamp:    time.Now(),
+		Message:      req.Message,
+		Response:     resp,
+		Profile:      cs.Profile,
+		FinishReason: services.FinishReasonName(reply.FinishReason),
+	}
+	if reply.Usage != nil {
+		turnRecord.PromptTokens = reply.Usage.PromptTokenCount
+		turnRecord.ResponseTokens = reply.Usage.CandidatesTokenCount
+
+		log.Printf("chat usage conversation=%s model=%s prompt_tokens=%d candidate_tokens=%d total_tokens=%d",
+			cs.ConversationID, reply.Model, reply.Usage.PromptTokenCount, reply.Usage.CandidatesTokenCount, reply.Usage.TotalTokenCount)
+	}
+
+	// Suggestions cost a second call, so callers opt in per request
+	var suggestions []string
+	var suggestTokens int32
+	if fiber.Query[bool](c, "suggestions") {
+		suggestions, suggestTokens = h.suggest(ctx, req.Message, resp)
+	}
+
+	var resetSummary string
+	if cfg.ResetSummary && prev != nil && prev != cs && prev.Profile != cs.Profile {
+		resetSummary = h.summarizeReset(ctx, cfg, prev)
+	}
+
+	turn := cs.RecordTurn(turnRecord)
+	h.Budget.Record(c.IP(), int(turnRecord.PromptTokens+turnRecord.ResponseTokens+suggestTokens))
+
+	if n := cfg.SummaryInterval; n > 0 && turn%n == 0 {
+		// Only the turns since the last update are folded into the summary
+		recent := cs.History()
+		if len(recent) > 2*n {
+			recent = recent[len(recent)-2*n:]
+		}
+		go h.updateSummary(cs, recent)
+	}
+
+	h.AI.MaybeHandoff(cs, turn, turnRecord.PromptTokens)
+
+	if cfg.FirstResponseDisclaimer != "" && cs.ShowDisclaimer() {
+		resp += "\n\n" + cfg.FirstResponseDisclaimer
+	}
+
+	if emergency {
+		resp = safety + "\n\n" + resp
+	}
+
+	result := fiber.Map{"response": resp, "conversation_id": cs.ConversationID}
+	debug := fiber.Query[bool](c, "debug")
+	if debug {
+		result["finish_reason"] = turnRecord.FinishReason
+	}
+	if debug || cfg.ProfileInResponse {
+		result["profile"] = turnRecord.Profile
+	}
+	if debug || cfg.TurnIDInResponse {
+		result["turn_id"] = turnRecord.ID
+	}
+	if resetSummary != "" {
+		result["previous_summary"] = resetSummary
+	}
+	if suggestions != nil {
+		result["suggestions"] = suggestions
+	}
+
+	outcome = "ok"
+
+	_, encodeSpan := h.tracer.Start(ctx, "chat.encode")
+	defer encodeSpan.End()
+
+	return c.JSON(result)
+}
+
+// suggest returns follow-up questions for the exchange and the tokens spent on
+// them. Failures only cost the suggestions, so they are logged and an empty
+// list is returned.
+func (h *ChatHandler) suggest(ctx context.Context, question, answer string) ([]string, int32) {
+	suggestions, tokens, err := h.AI.Suggest(ctx, question, answer)
+	if err != nil {
+		log.Printf("failed to generate follow-up suggestions: %v", err)
+		return []string{}, tokens
+	}
+
+	if h.Redactor.Enabled() {
+		for i, text := range suggestions {
+			suggestions[i], _ = h.Redactor.Redact(text)
+		}
+	}
+
+	return suggestions, tokens
+}
+
+// Validate runs the chat endpoint's input checks without calling the model.
+func (h *ChatHandler) Validate(c fiber.Ctx) error {
+	req, problem := parseChatRequest(c)
+	if problem != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": problem})
+	}
+
+	reasons := h.inputProblems(h.Config.Load(), req)
+
+	return c.JSON(fiber.Map{"accepted": len(reasons) == 0, "reasons": reasons})
+}
+
+// parseChatRequest decodes the body, telling an empty body and a missing
+// message apart from malformed JSON.
+func parseChatRequest(c fiber.Ctx) (models.ChatMessageRequest, string) {
 	var req models.ChatMessageRequest
+
+	body := bytes.TrimSpace(c.Body())
+	if len(body) == 0 {
+		return req, "request body is required"
+	}
+
 	if err := c.Bind().JSON(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		return req, "invalid request"
 	}
 
-	ip := c.IP()
-	if ip == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid IP"})
+	var fields struct {
+		Message *string `json:"message"`
+	}
+	if json.Unmarshal(body, &fields) != nil || fields.Message == nil {
+		return req, "message field is required"
 	}
 
-	cs := h.Sessions.GetOrCreate(ip, h.AI.StartChat)
+	return req, ""
+}
+
+// inputProblems lists why a chat request would be turned away before reaching the model.
+func (h *ChatHandler) inputProblems(cfg *models.Config, req models.ChatMessageRequest) []string {
+	reasons := []string{}
+
+	if utf8.RuneCountInString(strings.TrimSpace(req.Message)) < cfg.MinMessageLength {
+		reasons = append(reasons, reasonTooShort)
+	}
+
+	if err := req.GenerationParams.Validate(); err != nil {
+		reasons = append(reasons, err.Error())
+	}
+
+	if !models.ValidVerbosity(req.Verbosity) {
+		reasons = append(reasons, "verbosity must be brief, normal or detailed")
+	}
+
+	if !h.AI.HasProfile(cmp.Or(req.Profile, services.DefaultProfile)) {
+		reasons = append(reasons, "unknown profile")
+	}
+
+	if req.Preset != "" && !h.AI.HasPreset(req.Preset) {
+		reasons = append(reasons, "unknown preset")
+	}
+
+	return reasons
+}
+
+func (h *ChatHandler) Summary(c fiber.Ctx) error {
+	cs, ok := h.Sessions.Get(h.sessionKey(h.Config.Load(), c))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session not found"})
+	}
+
+	return c.JSON(fiber.Map{"conversation_id": cs.ConversationID, "summary": cs.Summary()})
+}
+
+// Rephrase rewrites the last answer of the caller's conversation. The original
+// turn and the model's history are left as they are.
+func (h *ChatHandler) Rephrase(c fiber.Ctx) error {
+	cfg := h.Config.Load()
+
+	var req models.RephraseRequest
+	if len(bytes.TrimSpace(c.Body())) > 0 {
+		if err := c.Bind().JSON(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+		}
+	}
 
-	resp, err := h.AI.Send(context.Background(), cs.Session, req.Message)
+	if !models.ValidRephraseTone(req.Tone) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown tone"})
+	}
+
+	cs, ok := h.Sessions.Get(h.sessionKey(cfg, c))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session not found"})
+	}
+
+	turn, ok := cs.LastTurn()
+	if !ok || turn.Response == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "nothing to rephrase"})
+	}
+
+	if !h.Budget.Allow(c.IP()) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Daily budget exhausted, please try again after the reset."})
+	}
+
+	left, ok := cs.TakeRephrase(turn.ID, cfg.RephraseLimit)
+	if !ok {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "This answer can't be rephrased again."})
+	}
+
+	rephrased, tokens, err := h.AI.Rephrase(c.Context(), turn.Response, req.Tone)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		cs.ReturnRephrase(turn.ID)
+		log.Printf("failed to rephrase turn %s: %v", turn.ID, err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to rephrase the answer"})
+	}
+	h.Budget.Record(c.IP(), int(tokens))
+
+	return c.JSON(fiber.Map{
+		"turn_id":        turn.ID,
+		"response":       h.postProcess(cfg, rephrased),
+		"rephrases_left": left,
+	})
+}
+
+func (h *ChatHandler) updateSummary(cs *services.ChatSession, history []*genai.Content) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	summary, err := h.AI.Summarize(ctx, cs.Summary(), history)
+	if err != nil {
+		log.Printf("failed to update session summary: %v", err)
+		return
+	}
+
+	cs.SetSummary(summary)
+}
+
+// summarizeReset summarises the conversation a profile switch replaced, or
+// returns "" when it was too short or the summary failed.
+func (h *ChatHandler) summarizeReset(ctx context.Context, cfg *models.Config, cs *services.ChatSession) string {
+	if len(cs.Turns()) < cfg.ResetSummaryMinTurns {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	summary, err := h.AI.Summarize(ctx, cs.Summary(), cs.History())
+	if err != nil {
+		log.Printf("failed to summarise conversation %s after a profile switch: %v", cs.ConversationID, err)
+		return ""
+	}
+
+	return summary
+}
+
+// userTime returns the current time in the zone named by the timezone header,
+// falling back to server time, or zero when date/time context is disabled.
+func (h *ChatHandler) userTime(cfg *models.Config, c fiber.Ctx) time.Time {
+	if !cfg.DatetimeContext {
+		return time.Time{}
+	}
+
+	if name := c.Get(cfg.TimezoneHeader); name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return time.Now().In(loc)
+		}
+	}
+
+	return time.Now()
+}
+
+// affinityHint derives an opaque routing value from the session key so the
+// edge never sees the raw cookie or IP. Sessions live in process memory, so
+// without sticky routing on this value a follow-up request that lands on
+// another instance starts a fresh conversation.
+func affinityHint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// sessionKey identifies the caller's session: the session cookie when one is
+// configured (issuing a new ID if missing), otherwise the client IP.
+func (h *ChatHandler) sessionKey(cfg *models.Config, c fiber.Ctx) string {
+	name := cfg.SessionCookieName
+	if name == "" {
+		return c.IP()
 	}
 
-	return c.JSON(fiber.Map{"response": resp})
+	if id := c.Cookies(name); uuid.Validate(id) == nil {
+		return id
+	}
+
+	id := uuid.NewString()
+	c.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    id,
+		Path:     "/",
+		Domain:   cfg.SessionCookieDomain,
+		SameSite: cfg.SessionCookieSameSite,
+		HTTPOnly: cfg.SessionCookieHTTPOnly,
+		Secure:   cfg.SessionCookieSecure,
+	})
+
+	return id
 }
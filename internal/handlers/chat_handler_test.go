@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestParseChatRequest(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", func(c fiber.Ctx) error {
+		_, problem := parseChatRequest(c)
+		return c.SendString(problem)
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty body", "", "request body is required"},
+		{"whitespace body", " \n\t", "request body is required"},
+		{"malformed json", `{"message": "hi"`, "invalid request"},
+		{"not an object", `["hi"]`, "invalid request"},
+		{"missing message", `{"profile": "default"}`, "message field is required"},
+		{"null message", `{"message": null}`, "message field is required"},
+		{"empty message", `{"message": ""}`, ""},
+		{"valid", `{"message": "How do I arm my alarm?"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+
+			if string(got) != tt.want {
+				t.Errorf("parseChatRequest(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
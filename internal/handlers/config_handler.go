@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/config"
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+type ConfigHandler struct {
+	Config *atomic.Pointer[models.Config]
+
+	// reconfigure rebuilds the services derived from the configuration, see Reload
+	reconfigure []func(*models.Config)
+}
+
+// NewConfigHandler serves the configuration held in cfg. Each reconfigure
+// function is called with a reloaded configuration before it is published.
+func NewConfigHandler(cfg *atomic.Pointer[models.Config], reconfigure ...func(*models.Config)) *ConfigHandler {
+	return &ConfigHandler{Config: cfg, reconfigure: reconfigure}
+}
+
+// Limits reports the client-facing limits so callers can pace themselves.
+func (h *ConfigHandler) Limits(c fiber.Ctx) error {
+	cfg := h.Config.Load()
+
+	limits := fiber.Map{
+		"ip": fiber.Map{
+			"max":    cfg.RateLimitMax,
+			"window": cfg.RateLimitWindow.String(),
+		},
+	}
+	if cfg.SessionRateLimitMax > 0 {
+		limits["session"] = fiber.Map{
+			"max":    cfg.SessionRateLimitMax,
+			"window": cfg.SessionRateLimitWindow.String(),
+		}
+	}
+
+	if cfg.GlobalRateLimit > 0 {
+		limits["global"] = fiber.Map{
+			"per_second": cfg.GlobalRateLimit,
+			"burst":      cfg.GlobalRateBurst,
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"rate_limits":        limits,
+		"min_message_length": cfg.MinMessageLength,
+		"max_response_words": cfg.MaxResponseWords,
+	})
+}
+
+func (h *ConfigHandler) Reload(c fiber.Ctx) error {
+	result, err := config.Reload(h.Config, h.reconfigure...)
+	if err != nil {
+		log.Printf("configuration reload rejected: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(result)
+}
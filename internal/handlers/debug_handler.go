@@ -1,19 +1,165 @@
 package handlers
 
 import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync/atomic"
+
 	"github.com/gofiber/fiber/v3"
 
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
 	"github.com/lavish440/Home-Security-Chatbot/internal/services"
 )
 
 type DebugHandler struct {
 	Sessions *services.SessionService
+	AI       *services.AIService
+	Load     *services.LoadMonitor
+	Stats    *services.StatsService
+	Config   *atomic.Pointer[models.Config]
 }
 
-func NewDebugHandler(s *services.SessionService) *DebugHandler {
-	return &DebugHandler{Sessions: s}
+func NewDebugHandler(s *services.SessionService, ai *services.AIService, load *services.LoadMonitor, stats *services.StatsService, cfg *atomic.Pointer[models.Config]) *DebugHandler {
+	return &DebugHandler{Sessions: s, AI: ai, Load: load, Stats: stats, Config: cfg}
 }
 
 func (h *DebugHandler) SessionsDump(c fiber.Ctx) error {
 	return c.JSON(h.Sessions.Dump())
 }
+
+func (h *DebugHandler) SessionTokens(c fiber.Ctx) error {
+	key := c.Params("key")
+
+	cs, ok := h.Sessions.Get(key)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session not found"})
+	}
+
+	turns, total, err := h.AI.CountHistoryTokens(c.Context(), cs.History())
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"session":      key,
+		"total_tokens": total,
+		"turns":        turns,
+	})
+}
+
+// SetSessionTier moves a session to one of SESSION_TIER_TIMEOUTS, changing how
+// long it may sit idle before cleanup. An empty tier restores the default.
+func (h *DebugHandler) SetSessionTier(c fiber.Ctx) error {
+	cfg := h.Config.Load()
+	key := c.Params("key")
+
+	cs, ok := h.Sessions.Get(key)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session not found"})
+	}
+
+	var req struct {
+		Tier string `json:"tier"`
+	}
+	if err := c.Bind().JSON(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	tier := strings.ToLower(req.Tier)
+	timeout, ok := cfg.SessionTierTimeouts[tier]
+	if !ok && tier != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown tier"})
+	}
+	cs.SetTier(tier, timeout)
+
+	return c.JSON(fiber.Map{
+		"session":      key,
+		"tier":         tier,
+		"idle_timeout": cmp.Or(timeout, cfg.SessionIdleTimeout).String(),
+	})
+}
+
+// SessionReplay returns the recorded turns of a session without calling the model.
+func (h *DebugHandler) SessionReplay(c fiber.Ctx) error {
+	key := c.Params("key")
+
+	cs, ok := h.Sessions.Get(key)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session not found"})
+	}
+
+	return c.JSON(fiber.Map{
+		"session":         key,
+		"conversation_id": cs.ConversationID,
+		"profile":         cs.Profile,
+		"handed_off":      cs.HandedOff(),
+		"summary":         cs.Summary(),
+		"devices":         cs.Devices(),
+		"turns":           cs.Turns(),
+	})
+}
+
+func (h *DebugHandler) PauseCleanup(c fiber.Ctx) error {
+	h.Sessions.PauseCleanup()
+	return c.JSON(fiber.Map{"cleanup_paused": true})
+}
+
+func (h *DebugHandler) ResumeCleanup(c fiber.Ctx) error {
+	h.Sessions.ResumeCleanup()
+	return c.JSON(fiber.Map{"cleanup_paused": false})
+}
+
+func (h *DebugHandler) WarmModel(c fiber.Ctx) error {
+	model := c.Query("model")
+	if !slices.Contains(h.AI.Models(), model) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "unknown model",
+			"models": h.AI.Models(),
+		})
+	}
+
+	latency, err := h.AI.Warm(c.Context(), model)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"model":      model,
+			"ok":         false,
+			"latency_ms": latency.Milliseconds(),
+			"error":      err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"model":      model,
+		"ok":         true,
+		"latency_ms": latency.Milliseconds(),
+	})
+}
+
+func (h *DebugHandler) Compression(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"level":        h.Load.Level().String(),
+		"load_per_cpu": h.Load.Load(),
+	})
+}
+
+// Dashboard aggregates the health signals an operator would otherwise collect
+// from several endpoints. It only reads in-memory state.
+func (h *DebugHandler) Dashboard(c fiber.Ctx) error {
+	available, total := h.AI.KeyStatus()
+
+	return c.JSON(fiber.Map{
+		"ready":          h.AI.Ready() && available > 0,
+		"sessions":       h.Sessions.Count(),
+		"cleanup_paused": h.Sessions.CleanupPaused(),
+		"window":         h.Stats.Window().String(),
+		"chat":           h.Stats.Snapshot(),
+		"upstream": fiber.Map{
+			"models":         h.AI.Models(),
+			"keys_available": available,
+			"keys_total":     total,
+		},
+		"compression": h.Load.Level().String(),
+		"token_cache": h.AI.TokenCacheStats(),
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/services"
+)
+
+type LogHandler struct {
+	Logs *services.LogStream
+}
+
+func NewLogHandler(logs *services.LogStream) *LogHandler {
+	return &LogHandler{Logs: logs}
+}
+
+// Stream sends the recent log lines followed by new ones as server-sent
+// events, optionally only those at or above ?level=warning or ?level=error.
+func (h *LogHandler) Stream(c fiber.Ctx) error {
+	level := c.Query("level", "info")
+	if !services.ValidLogLevel(level) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "level must be info, warning or error"})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+
+	recent, lines, unsubscribe := h.Logs.Subscribe()
+
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		fmt.Fprint(w, ": connected\n\n")
+		for _, line := range recent {
+			if services.AtLeast(line, level) {
+				writeEvent(w, line)
+			}
+		}
+		if w.Flush() != nil {
+			return
+		}
+
+		// Comments keep idle connections alive and detect clients that went away
+		keepalive := time.NewTicker(15 * time.Second)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case line := <-lines:
+				if !services.AtLeast(line, level) {
+					continue
+				}
+				writeEvent(w, line)
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			}
+
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+}
+
+func writeEvent(w *bufio.Writer, line string) {
+	fmt.Fprintf(w, "data: %s\n\n", strings.ReplaceAll(line, "\n", " "))
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v3"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/services"
+)
+
+type ProfileHandler struct {
+	AI *services.AIService
+}
+
+func NewProfileHandler(ai *services.AIService) *ProfileHandler {
+	return &ProfileHandler{AI: ai}
+}
+
+func (h *ProfileHandler) List(c fiber.Ctx) error {
+	return c.JSON(fiber.Map{"profiles": h.AI.Profiles()})
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/compress"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/services"
+)
+
+// LogStreamPath serves an endless event stream. The compress middleware reads
+// the whole body before compressing it, so the stream must bypass it.
+const LogStreamPath = "/api/debug/logs/stream"
+
+func skipCompression(c fiber.Ctx) bool {
+	return c.Path() == LogStreamPath
+}
+
+// AdaptiveCompress drops from best to fast compression, or disables it, while the host is under load.
+func AdaptiveCompress(load *services.LoadMonitor) func(fiber.Ctx) error {
+	best := compress.New(compress.Config{Next: skipCompression, Level: compress.LevelBestCompression})
+	fast := compress.New(compress.Config{Next: skipCompression, Level: compress.LevelBestSpeed})
+
+	return func(c fiber.Ctx) error {
+		switch load.Level() {
+		case services.CompressionOff:
+			return c.Next()
+		case services.CompressionFast:
+			return fast(c)
+		default:
+			return best(c)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/services"
+)
+
+// DeadLetters records requests that end with at least minStatus. The route
+// pattern is logged instead of the path so session keys in URLs stay out.
+func DeadLetters(dead *services.DeadLetterLog, minStatus int) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		errorType := ""
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+			errorType = fmt.Sprintf("%T", err)
+		}
+
+		if status >= minStatus {
+			dead.Record(services.DeadLetter{
+				RequestID: requestid.FromContext(c),
+				Timestamp: time.Now(),
+				Method:    c.Method(),
+				Route:     c.Route().Path,
+				Status:    status,
+				ErrorType: cmp.Or(errorType, http.StatusText(status)),
+			})
+		}
+
+		return err
+	}
+}
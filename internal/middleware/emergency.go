@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// emergencyKey holds the safety message of a request whose message describes
+// an emergency, see Emergency.
+type emergencyKey struct{}
+
+// Emergency checks chat messages posted to path before any limit can turn
+// them away, so that a rejection still carries the safety message. detect
+// returns the safety message for a message text, or "" when it is not an
+// emergency.
+func Emergency(path string, detect func(msg string) string) func(fiber.Ctx) error {
+	return func(c fiber.Ctx) error {
+		if c.Method() != fiber.MethodPost || c.Path() != path {
+			return c.Next()
+		}
+
+		// Malformed bodies are left for the handler to reject
+		var body struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(c.Body(), &body) == nil {
+			if safety := detect(body.Message); safety != "" {
+				c.Locals(emergencyKey{}, safety)
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+// EmergencyMessage returns the safety message for the request, if Emergency
+// found its message to describe an emergency.
+func EmergencyMessage(c fiber.Ctx) (string, bool) {
+	safety, ok := c.Locals(emergencyKey{}).(string)
+	return safety, ok
+}
+
+// Reject answers with status and msg as the error. An emergency also gets its
+// safety message as the response, so it is shown whatever stopped the request.
+func Reject(c fiber.Ctx, status int, msg string) error {
+	body := fiber.Map{"error": msg}
+	if safety, ok := EmergencyMessage(c); ok {
+		body["response"] = safety
+	}
+
+	return c.Status(status).JSON(body)
+}
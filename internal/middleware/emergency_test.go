@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestRejectCarriesSafetyMessage(t *testing.T) {
+	const safety = "Call emergency services now."
+	detect := func(msg string) string {
+		if strings.Contains(msg, "fire") {
+			return safety
+		}
+		return ""
+	}
+
+	app := fiber.New()
+	app.Use(Emergency("/api/chat", detect))
+	app.Post("/api/chat", func(c fiber.Ctx) error {
+		return Reject(c, fiber.StatusTooManyRequests, "Too many requests, please try again later.")
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"emergency", `{"message":"there is a fire in the garage"}`, safety},
+		{"ordinary message", `{"message":"how do I reset my camera"}`, ""},
+		{"malformed body", `{"message":`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, "/api/chat", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var body map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != fiber.StatusTooManyRequests || body["error"] == "" {
+				t.Errorf("status %d, error %q; want a 429 with an error", resp.StatusCode, body["error"])
+			}
+			if body["response"] != tt.want {
+				t.Errorf("response = %q, want %q", body["response"], tt.want)
+			}
+		})
+	}
+}
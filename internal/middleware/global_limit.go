@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/time/rate"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+// GlobalLimiter caps the total request rate across all clients with a token
+// bucket, as a hard ceiling that protects the shared Gemini quota. The rate and
+// burst follow GLOBAL_RATE_LIMIT and GLOBAL_RATE_BURST in the current
+// configuration, and a zero rate lets every request through.
+func GlobalLimiter(cfg *atomic.Pointer[models.Config]) func(fiber.Ctx) error {
+	start := cfg.Load()
+	bucket := rate.NewLimiter(rate.Limit(start.GlobalRateLimit), start.GlobalRateBurst)
+
+	return func(c fiber.Ctx) error {
+		current := cfg.Load()
+		if current.GlobalRateLimit <= 0 {
+			return c.Next()
+		}
+
+		// The bucket is safe for concurrent use and keeps its tokens across a change
+		if limit := rate.Limit(current.GlobalRateLimit); bucket.Limit() != limit {
+			bucket.SetLimit(limit)
+		}
+		if bucket.Burst() != current.GlobalRateBurst {
+			bucket.SetBurst(current.GlobalRateBurst)
+		}
+
+		if !bucket.Allow() {
+			return Reject(c, fiber.StatusServiceUnavailable, "The assistant is busy right now, please try again shortly.")
+		}
+		return c.Next()
+	}
+}
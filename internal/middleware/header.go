@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RequireHeader rejects requests that don't carry the given header value,
+// e.g. a shared secret injected by the CDN in front of the origin.
+func RequireHeader(name, value string) func(fiber.Ctx) error {
+	return func(c fiber.Ctx) error {
+		if subtle.ConstantTimeCompare([]byte(c.Get(name)), []byte(value)) != 1 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Forbidden"})
+		}
+		return c.Next()
+	}
+}
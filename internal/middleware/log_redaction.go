@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"encoding/json"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/logger"
+)
+
+const redactedValue = "[REDACTED]"
+
+// redactedTags overrides the logger tags that can leak secrets so that request
+// and response header values, cookies, query and form parameters and JSON body
+// fields on the redaction list are masked.
+func redactedTags(fields []string) map[string]logger.LogFunc {
+	redact := func(name string) bool {
+		return slices.ContainsFunc(fields, func(f string) bool {
+			return strings.EqualFold(f, name)
+		})
+	}
+
+	return map[string]logger.LogFunc{
+		logger.TagReqHeaders: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+			headers := c.GetReqHeaders()
+
+			pairs := make([]string, 0, len(headers))
+			for _, k := range slices.Sorted(maps.Keys(headers)) {
+				v := strings.Join(headers[k], ",")
+				if redact(k) {
+					v = redactedValue
+				}
+				pairs = append(pairs, k+"="+v)
+			}
+
+			return output.WriteString(strings.Join(pairs, "&"))
+		},
+		logger.TagReqHeader: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, name string) (int, error) {
+			if redact(name) {
+				return output.WriteString(redactedValue)
+			}
+			return output.WriteString(c.Get(name))
+		},
+		logger.TagRespHeader: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, name string) (int, error) {
+			if redact(name) {
+				return output.WriteString(redactedValue)
+			}
+			return output.WriteString(c.GetRespHeader(name))
+		},
+		logger.TagCookie: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, name string) (int, error) {
+			if redact(name) {
+				return output.WriteString(redactedValue)
+			}
+			return output.WriteString(c.Cookies(name))
+		},
+		logger.TagQuery: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, name string) (int, error) {
+			if redact(name) {
+				return output.WriteString(redactedValue)
+			}
+			return output.WriteString(fiber.Query[string](c, name))
+		},
+		logger.TagForm: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, name string) (int, error) {
+			if redact(name) {
+				return output.WriteString(redactedValue)
+			}
+			return output.WriteString(c.FormValue(name))
+		},
+		logger.TagQueryStringParams: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+			return output.WriteString(redactQuery(string(c.Request().URI().QueryString()), redact))
+		},
+		logger.TagURL: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+			u := c.OriginalURL()
+			if path, query, ok := strings.Cut(u, "?"); ok {
+				u = path + "?" + redactQuery(query, redact)
+			}
+			return output.WriteString(u)
+		},
+		logger.TagBody: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+			return output.WriteString(redactBody(c.Body(), redact))
+		},
+		logger.TagResBody: func(output logger.Buffer, c fiber.Ctx, _ *logger.Data, _ string) (int, error) {
+			return output.WriteString(redactBody(c.Response().Body(), redact))
+		},
+	}
+}
+
+// redactQuery masks the values of matching parameters in a query string.
+func redactQuery(query string, redact func(string) bool) string {
+	// A malformed pair is dropped, the rest are still parsed
+	values, _ := url.ParseQuery(query)
+
+	pairs := make([]string, 0, len(values))
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		for _, v := range values[k] {
+			if redact(k) {
+				v = redactedValue
+			}
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// redactBody masks matching top-level fields of a JSON object body.
+// Bodies that aren't JSON objects are logged unchanged.
+func redactBody(body []byte, redact func(string) bool) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return string(body)
+	}
+
+	masked, _ := json.Marshal(redactedValue)
+	for k := range fields {
+		if redact(k) {
+			fields[k] = masked
+		}
+	}
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return string(body)
+	}
+
+	return string(out)
+}
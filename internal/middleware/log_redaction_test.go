@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/logger"
+)
+
+func TestRedactedTags(t *testing.T) {
+	var out bytes.Buffer
+
+	app := fiber.New()
+	app.Use(logger.New(logger.Config{
+		Format:     "${reqHeaders} | ${reqHeader:authorization} | ${cookie:sid} | ${cookie:theme} | ${body}\n",
+		Stream:     &out,
+		CustomTags: redactedTags([]string{"Authorization", "Cookie", "sid", "password"}),
+	}))
+	app.Post("/", func(c fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	req := httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader(`{"message":"hello","password":"hunter2"}`))
+	req.Header.Set("Authorization", "Basic YTpi")
+	req.Header.Set("Cookie", "sid=0b8f6c1e; theme=dark")
+	req.Header.Set("Content-Type", "application/json")
+
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	line := out.String()
+	for _, secret := range []string{"YTpi", "0b8f6c1e", "hunter2"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("log line contains %q: %s", secret, line)
+		}
+	}
+	for _, kept := range []string{"Content-Type=application/json", "| dark |", `"message":"hello"`} {
+		if !strings.Contains(line, kept) {
+			t.Errorf("log line is missing %q: %s", kept, line)
+		}
+	}
+}
+
+func TestRedactedResponseAndQueryTags(t *testing.T) {
+	var out bytes.Buffer
+
+	app := fiber.New()
+	app.Use(logger.New(logger.Config{
+		Format:     "${respHeader:Set-Cookie} | ${resBody} | ${queryParams} | ${query:token} | ${query:page} | ${form:password} | ${url}\n",
+		Stream:     &out,
+		CustomTags: redactedTags([]string{"Set-Cookie", "token", "password"}),
+	}))
+	app.Post("/", func(c fiber.Ctx) error {
+		c.Cookie(&fiber.Cookie{Name: "sid", Value: "0b8f6c1e"})
+		return c.JSON(fiber.Map{"answer": "ok", "token": "t0k3n"})
+	})
+
+	req := httptest.NewRequest(fiber.MethodPost, "/?token=q5ecret&page=2", strings.NewReader("password=hunter2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+
+	line := out.String()
+	for _, secret := range []string{"0b8f6c1e", "t0k3n", "q5ecret", "hunter2"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("log line contains %q: %s", secret, line)
+		}
+	}
+	for _, kept := range []string{`"answer":"ok"`, "page=2", "| 2 |", "/?page=2&token=[REDACTED]"} {
+		if !strings.Contains(line, kept) {
+			t.Errorf("log line is missing %q: %s", kept, line)
+		}
+	}
+}
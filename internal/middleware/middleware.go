@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/contrib/v3/monitor"
@@ -11,11 +12,19 @@ import (
 	"github.com/gofiber/fiber/v3/middleware/limiter"
 	"github.com/gofiber/fiber/v3/middleware/logger"
 	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
 
 	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+	"github.com/lavish440/Home-Security-Chatbot/internal/services"
 )
 
-func Register(app *fiber.App, cfg *models.Config) {
+// Register installs the middleware every route shares. Only the per-IP rate
+// limit follows a reload of live, the rest is set up from the configuration at startup.
+func Register(app *fiber.App, live *atomic.Pointer[models.Config], load *services.LoadMonitor, dead *services.DeadLetterLog, emergency func(fiber.Ctx) error) {
+	cfg := live.Load()
+
+	app.Use(requestid.New())
+
 	// CORS
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: []string{cfg.Origin},
@@ -23,30 +32,52 @@ func Register(app *fiber.App, cfg *models.Config) {
 	}))
 
 	// Compression
-	app.Use(compress.New(compress.Config{
-		Level: compress.LevelBestCompression,
-	}))
+	if cfg.AdaptiveCompression {
+		app.Use(AdaptiveCompress(load))
+	} else {
+		app.Use(compress.New(compress.Config{
+			Next:  skipCompression,
+			Level: compress.LevelBestCompression,
+		}))
+	}
+
+	// Failed requests, outside recover so recovered panics count too
+	if dead != nil {
+		app.Use(DeadLetters(dead, cfg.DeadLetterMinStatus))
+	}
 
 	// Panic recovery
 	app.Use(recover.New())
 
 	// Logger
 	app.Use(logger.New(logger.Config{
+		Format:     cfg.LogFormat,
 		TimeFormat: "02-Jan-2006 03:04:05 PM",
+		CustomTags: redactedTags(cfg.LogRedactFields),
 	}))
 
+	// Edge secret header
+	if cfg.RequiredHeader != "" {
+		app.Use(RequireHeader(cfg.RequiredHeader, cfg.RequiredHeaderValue))
+	}
+
 	if cfg.EnableMonitoring {
 		app.Get("/metrics", monitor.New())
 	}
 
+	// Emergencies are flagged before the limiters so their rejections carry the safety message
+	app.Use(emergency)
+
 	// Rate limiter
 	app.Use(limiter.New(limiter.Config{
-		Max:        1000,
-		Expiration: 30 * time.Minute,
+		MaxFunc: func(fiber.Ctx) int {
+			return max(live.Load().RateLimitMax, 0)
+		},
+		ExpirationFunc: func(fiber.Ctx) time.Duration {
+			return live.Load().RateLimitWindow
+		},
 		LimitReached: func(c fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Too many requests, please try again later.",
-			})
+			return Reject(c, fiber.StatusTooManyRequests, "Too many requests, please try again later.")
 		},
 	}))
 
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/limiter"
+	"github.com/google/uuid"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+// SessionLimiter limits requests per session cookie on top of the per-IP
+// limiter, so one conversation can't get around it by rotating IPs. Requests
+// without a valid session cookie are left to the per-IP limit alone. The limit
+// and window follow SESSION_RATE_LIMIT_MAX and SESSION_RATE_LIMIT_WINDOW in the
+// current configuration, and a zero limit turns it off.
+func SessionLimiter(cookie string, cfg *atomic.Pointer[models.Config]) func(fiber.Ctx) error {
+	return limiter.New(limiter.Config{
+		MaxFunc: func(fiber.Ctx) int {
+			return max(cfg.Load().SessionRateLimitMax, 0)
+		},
+		ExpirationFunc: func(fiber.Ctx) time.Duration {
+			return cfg.Load().SessionRateLimitWindow
+		},
+		Next: func(c fiber.Ctx) bool {
+			return uuid.Validate(c.Cookies(cookie)) != nil
+		},
+		KeyGenerator: func(c fiber.Ctx) string {
+			return c.Cookies(cookie)
+		},
+		LimitReached: func(c fiber.Ctx) error {
+			return Reject(c, fiber.StatusTooManyRequests, "Too many messages in this conversation, please try again later.")
+		},
+	})
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Budget reports what is left of a caller's allowance for the current period.
+// A nil remaining value means that dimension is unlimited.
+type Budget struct {
+	Unlimited         bool      `json:"unlimited"`
+	RemainingRequests *int      `json:"remaining_requests"`
+	RemainingTokens   *int      `json:"remaining_tokens"`
+	ResetAt           time.Time `json:"reset_at"`
+}
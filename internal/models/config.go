@@ -1,13 +1,130 @@
 package models
 
+import (
+	"regexp"
+	"time"
+)
+
 type Config struct {
-	Port             string
-	GeminiAPIKey     string
-	Origin           string
-	ReverseProxyIP   string
-	EnforceHTTPS     bool
-	EnableMonitoring bool
-	EnableDebug      bool
-	BasicAuthUser    string
-	BasicAuthPass    string
+	Port              string
+	GeminiAPIKeys     []string
+	GeminiKeyCooldown time.Duration
+	GeminiModel       string
+	ModelCheck        bool
+	GeminiEndpoint    string
+	Origin            string
+	ReverseProxyIP    string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSMinVersion     uint16
+	TLSCipherSuites   []uint16
+	EnforceHTTPS      bool
+	EnableMonitoring  bool
+	EnableDebug       bool
+	BasicAuthUser     string
+	BasicAuthPass     string
+	StaticDir         string
+	StaticHosts       map[string]string
+	StaticFallback    bool
+	MinMessageLength  int
+	MinMessageMode    string
+	SummaryInterval   int
+	// HistoryTurns caps the turns kept in a session's history sent to the
+	// model. Older turns are dropped and the summary stands in for them.
+	HistoryTurns int
+	// ResetSummary returns a summary of the conversation a profile switch
+	// replaces, if it had at least ResetSummaryMinTurns turns.
+	ResetSummary         bool
+	ResetSummaryMinTurns int
+
+	SessionStore          string
+	SessionCookieName     string
+	SessionCookieDomain   string
+	SessionCookieSameSite string
+	SessionCookieHTTPOnly bool
+	SessionCookieSecure   bool
+	SessionIdleTimeout    time.Duration
+	// SessionTierTimeouts maps tier names to idle timeouts that replace
+	// SessionIdleTimeout for sessions assigned to that tier.
+	SessionTierTimeouts map[string]time.Duration
+
+	// SessionAffinityHeader names a response header carrying a stable hash of
+	// the session key, for edges that route sessions to the same instance.
+	SessionAffinityHeader string
+
+	DatetimeContext bool
+	TimezoneHeader  string
+
+	FingerprintHeader      string
+	FingerprintMergeWindow time.Duration
+
+	PromptProfiles    map[string]PromptProfile
+	Presets           map[string]GenerationParams
+	DefaultPreset     string
+	ProfileInResponse bool
+	TurnIDInResponse  bool
+
+	EmergencyDetection bool
+	EmergencyKeywords  []string
+	EmergencyMessage   string
+
+	DeviceDetection bool
+	DeviceBrands    []string
+
+	HandoffModel       string
+	HandoffAfterTurns  int
+	HandoffAfterTokens int
+
+	RedactPatterns []*regexp.Regexp
+
+	ShutdownTimeout time.Duration
+
+	DeadLetterDir       string
+	DeadLetterRetention time.Duration
+	DeadLetterMinStatus int
+
+	LogFormat       string
+	LogStreamSize   int
+	LogRedactFields []string
+
+	FirstResponseDisclaimer string
+	ReadingLevel            string
+	Examples                []Example
+	RephraseLimit           int
+	MaxResponseWords        int
+
+	VerbosityBriefMaxTokens    int
+	VerbosityDetailedMaxTokens int
+
+	RequiredHeader      string
+	RequiredHeaderValue string
+
+	AdaptiveCompression bool
+	CompressionFastLoad float64
+	CompressionOffLoad  float64
+
+	DailyRequestBudget int
+	DailyTokenBudget   int
+
+	LowQualityRetry       bool
+	LowQualityMinQuestion int
+	LowQualityMinAnswer   int
+	LowQualityPhrases     []string
+
+	EmptyResponseRetry bool
+	TokenCacheSize     int
+
+	RateLimitMax           int
+	RateLimitWindow        time.Duration
+	SessionRateLimitMax    int
+	SessionRateLimitWindow time.Duration
+	GlobalRateLimit        float64
+	GlobalRateBurst        int
+
+	ExportWebhookURL string
+
+	StartupWarmup  bool
+	StartupMessage string
+
+	OTelEndpoint string
 }
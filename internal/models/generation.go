@@ -0,0 +1,39 @@
+package models
+
+import "errors"
+
+// GenerationParams are optional overrides of the model's generation settings.
+type GenerationParams struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopK        *int32   `json:"top_k,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	MaxTokens   *int32   `json:"max_tokens,omitempty"`
+}
+
+func floatParam(v float32) *float32 { return &v }
+func intParam(v int32) *int32       { return &v }
+
+// DefaultPresets are the built-in named parameter sets. Balanced matches the
+// model defaults, so it changes nothing unless a preset file redefines it.
+var DefaultPresets = map[string]GenerationParams{
+	"creative": {Temperature: floatParam(1.0), TopK: intParam(64), TopP: floatParam(0.95)},
+	"balanced": {Temperature: floatParam(0.7), TopK: intParam(40), TopP: floatParam(0.9)},
+	"precise":  {Temperature: floatParam(0.2), TopK: intParam(20), TopP: floatParam(0.8)},
+}
+
+func (p GenerationParams) Validate() error {
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return errors.New("temperature must be between 0 and 2")
+	}
+	if p.TopK != nil && *p.TopK < 1 {
+		return errors.New("top_k must be at least 1")
+	}
+	if p.TopP != nil && (*p.TopP < 0 || *p.TopP > 1) {
+		return errors.New("top_p must be between 0 and 1")
+	}
+	if p.MaxTokens != nil && *p.MaxTokens < 1 {
+		return errors.New("max_tokens must be at least 1")
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+type PromptProfile struct {
+	Description string `json:"description"`
+	Prompt      string `json:"prompt"`
+	Preset      string `json:"preset,omitempty"`
+
+	GenerationParams
+}
+
+type ProfileInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Example is a few-shot question and answer pair injected into the system instruction.
+type Example struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
@@ -0,0 +1,20 @@
+package models
+
+const (
+	RephraseSimpler   = "simpler"
+	RephraseDifferent = "different"
+)
+
+type RephraseRequest struct {
+	Tone string `json:"tone,omitempty"`
+}
+
+// ValidRephraseTone reports whether t is a known rephrasing tone. An empty value means simpler.
+func ValidRephraseTone(t string) bool {
+	switch t {
+	case "", RephraseSimpler, RephraseDifferent:
+		return true
+	}
+
+	return false
+}
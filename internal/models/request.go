@@ -1,5 +1,13 @@
 package models
 
 type ChatMessageRequest struct {
-	Message string `json:"message"`
+	Message   string `json:"message"`
+	Profile   string `json:"profile,omitempty"`
+	Verbosity string `json:"verbosity,omitempty"`
+	Preset    string `json:"preset,omitempty"`
+	// ConversationID is the conversation_id of the client's last response,
+	// needed to carry that conversation over when its IP changed.
+	ConversationID string `json:"conversation_id,omitempty"`
+
+	GenerationParams
 }
@@ -0,0 +1,6 @@
+package models
+
+type TurnTokens struct {
+	Role   string `json:"role"`
+	Tokens int32  `json:"tokens"`
+}
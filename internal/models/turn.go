@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type Turn struct {
+	ID             string    `json:"turn_id"`
+	Index          int       `json:"index"`
+	Timestamp      time.Time `json:"timestamp"`
+	Message        string    `json:"message"`
+	Response       string    `json:"response"`
+	Profile        string    `json:"profile"`
+	FinishReason   string    `json:"finish_reason,omitempty"`
+	PromptTokens   int32     `json:"prompt_tokens"`
+	ResponseTokens int32     `json:"response_tokens"`
+}
@@ -0,0 +1,17 @@
+package models
+
+const (
+	VerbosityBrief    = "brief"
+	VerbosityNormal   = "normal"
+	VerbosityDetailed = "detailed"
+)
+
+// ValidVerbosity reports whether v is a known verbosity level. An empty value keeps the session's level.
+func ValidVerbosity(v string) bool {
+	switch v {
+	case "", VerbosityBrief, VerbosityNormal, VerbosityDetailed:
+		return true
+	}
+
+	return false
+}
@@ -1,24 +1,270 @@
 package services
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+const DefaultProfile = "default"
+
+const defaultPrompt = "You are a specialized AI assistant for home security systems. Answer the following question about home security. If the question is not related to home security, politely decline to answer and explain that you only answer questions about home security systems, cameras, alarms, sensors, etc. Keep responses concise, informative, and helpful for home owners. If the user asks you to control a home security device, behave as if you have done it."
+
+const summaryInstruction = "You maintain a compact running summary of a conversation between a home owner and a home security assistant. Merge the previous summary (if any) with the new conversation turns. Keep the devices, settings, problems and advice that matter for future questions. Reply with the summary only, in a few sentences."
+
+// summaryPreamble and summaryAcknowledgement frame the summary of dropped turns, see summaryContext
+const summaryPreamble = "Summary of our conversation so far, for context:\n"
+const summaryAcknowledgement = "Thanks, I'll keep that in mind."
+
+const rephraseInstruction = "You rewrite answers from a home security assistant for a home owner who did not understand them. Keep every fact, setting and step from the original answer and add nothing new. Reply with the rewritten answer only."
+
+var rephraseTones = map[string]string{
+	models.RephraseSimpler:   "Rewrite this answer in plain, simple language with short sentences and no jargon:\n\n",
+	models.RephraseDifferent: "Rewrite this answer with a different structure and tone:\n\n",
+}
+
+const suggestInstruction = "You suggest follow-up questions a home owner might ask a home security assistant next. Given a question and its answer, reply with a JSON array of two or three short, distinct questions about home security."
+
+// countTokensParallelism bounds the concurrent CountTokens requests for one history
+const countTokensParallelism = 4
+
+// maxSuggestionLength drops run-on suggestions, which are usually malformed output
+const maxSuggestionLength = 150
+
+// ErrModelUnavailable is returned when Gemini doesn't know the configured model.
+var ErrModelUnavailable = errors.New("model is unavailable")
+
+// ErrEmptyResponse is returned when the model answers with no usable text.
+var ErrEmptyResponse = errors.New("model returned an empty response")
+
+const (
+	verbosityBrief    = "\n\nThe user asked for brief answers: reply in two or three sentences with only the essential advice."
+	verbosityDetailed = "\n\nThe user asked for detailed answers: explain your reasoning, cover the relevant options and give step-by-step instructions where useful."
 )
 
+const deviceControlUnavailable = "Device control isn't available right now, so I can't operate your devices directly. I can still walk you through doing it yourself in your security system's app or panel."
+
 type AIService struct {
-	client *genai.Client
-	model  *genai.GenerativeModel
+	pool   *keyPool
+	tokens *tokenCache
+
+	// state is replaced as a whole by Reconfigure, so a request loads it once
+	// and works with a single configuration throughout
+	state atomic.Pointer[aiState]
+
+	// ready is cleared until WarmUp finishes when startup warm-up is enabled
+	ready atomic.Bool
+}
+
+// SendOptions tunes a single message without changing the session's defaults.
+type SendOptions struct {
+	Params    models.GenerationParams
+	Preset    string
+	Verbosity string
+	// Now is added to the system instruction when set, in the user's time zone
+	Now time.Time
+}
+
+// Reply is the model's answer to a single chat message.
+type Reply struct {
+	Text         string
+	Model        string
+	FinishReason genai.FinishReason
+	Usage        *genai.UsageMetadata
+}
+
+// aiState is the configuration the service answers with and the profile
+// models built from it.
+type aiState struct {
+	cfg      *models.Config
+	profiles map[string]models.PromptProfile
+	// models holds each backend's profile models, by backend index
+	models []profileModels
+}
+
+// profileModels are the chat models of every prompt profile on one backend.
+type profileModels struct {
+	chat    map[string]*genai.GenerativeModel
+	handoff map[string]*genai.GenerativeModel
+}
+
+// model returns the profile's chat or handoff model. A session whose profile
+// was removed by a reload falls back to the default profile.
+func (m profileModels) model(profile string, handedOff bool) *genai.GenerativeModel {
+	if _, ok := m.chat[profile]; !ok {
+		profile = DefaultProfile
+	}
+
+	if model, ok := m.handoff[profile]; ok && handedOff {
+		return model
+	}
+
+	return m.chat[profile]
+}
+
+func NewAIService(ctx context.Context, cfg *models.Config) (*AIService, error) {
+	pool := &keyPool{cooldown: cfg.GeminiKeyCooldown}
+	for i, key := range cfg.GeminiAPIKeys {
+		b, err := newBackend(ctx, cfg, key)
+		if err != nil {
+			return nil, fmt.Errorf("gemini API key #%d: %w", i+1, err)
+		}
+
+		b.index = i
+		pool.backends = append(pool.backends, b)
+	}
+
+	s := &AIService{
+		pool:   pool,
+		tokens: newTokenCache(cfg.TokenCacheSize),
+	}
+	s.Reconfigure(cfg)
+	s.ready.Store(!cfg.StartupWarmup)
+
+	if cfg.ModelCheck {
+		if err := s.checkModels(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Reconfigure rebuilds the profile models from cfg, picking up changed prompts,
+// presets, examples and response constraints, and answers later requests with
+// cfg. Requests already running finish with the previous configuration. The
+// API keys, endpoint and model names are fixed when the service is created.
+func (s *AIService) Reconfigure(cfg *models.Config) {
+	profiles := map[string]models.PromptProfile{
+		DefaultProfile: {Description: "General home security assistant", Prompt: defaultPrompt},
+	}
+	maps.Copy(profiles, cfg.PromptProfiles)
+
+	state := &aiState{cfg: cfg, profiles: profiles}
+	for _, b := range s.pool.backends {
+		state.models = append(state.models, newProfileModels(b.client, cfg, profiles))
+	}
+
+	s.state.Store(state)
 }
 
-func NewAIService(ctx context.Context, apiKey string) (*AIService, error) {
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+// checkModels looks up every configured model so a misspelt name stops the
+// server at startup. Only a definite not-found fails; other errors such as a
+// network outage are logged so the server can still start.
+func (s *AIService) checkModels(ctx context.Context) error {
+	client := s.pool.active().client
+
+	for _, name := range s.Models() {
+		_, err := client.GenerativeModel(name).Info(ctx)
+		if isNotFoundError(err) {
+			return fmt.Errorf("%w: %s", ErrModelUnavailable, name)
+		}
+		if err != nil {
+			log.Printf("could not verify model %s: %v", name, requestError(err))
+		}
+	}
+
+	return nil
+}
+
+func newBackend(ctx context.Context, cfg *models.Config, apiKey string) (*backend, error) {
+	opts := []option.ClientOption{option.WithAPIKey(apiKey)}
+	if cfg.GeminiEndpoint != "" {
+		opts = append(opts, option.WithEndpoint(cfg.GeminiEndpoint))
+	}
+
+	client, err := genai.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	model := client.GenerativeModel("gemini-flash-latest")
+	b := &backend{client: client}
+
+	b.summarizer = client.GenerativeModel(cfg.GeminiModel)
+	b.summarizer.SetTemperature(0.2)
+	b.summarizer.SetMaxOutputTokens(512)
+	b.summarizer.ResponseMIMEType = "text/plain"
+	b.summarizer.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(summaryInstruction)}}
+
+	// Counting with a chat model would add its system instruction to every count
+	b.counter = client.GenerativeModel(cfg.GeminiModel)
+
+	b.suggester = client.GenerativeModel(cfg.GeminiModel)
+	b.suggester.SetTemperature(0.7)
+	b.suggester.SetMaxOutputTokens(256)
+	b.suggester.ResponseMIMEType = "application/json"
+	b.suggester.ResponseSchema = &genai.Schema{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}}
+	b.suggester.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(suggestInstruction)}}
+
+	b.rephraser = client.GenerativeModel(cfg.GeminiModel)
+	b.rephraser.SetTemperature(0.7)
+	b.rephraser.ResponseMIMEType = "text/plain"
+	b.rephraser.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(rephraseInstruction)}}
+
+	return b, nil
+}
+
+func newProfileModels(client *genai.Client, cfg *models.Config, profiles map[string]models.PromptProfile) profileModels {
+	m := profileModels{
+		chat:    make(map[string]*genai.GenerativeModel, len(profiles)),
+		handoff: make(map[string]*genai.GenerativeModel, len(profiles)),
+	}
+
+	for name, profile := range profiles {
+		prompt := systemPrompt(cfg, profile.Prompt)
+
+		m.chat[name] = newChatModel(client, cfg.GeminiModel, prompt)
+		applyProfileParams(m.chat[name], cfg, profile)
+
+		if cfg.HandoffModel != "" {
+			m.handoff[name] = newChatModel(client, cfg.HandoffModel, prompt)
+			applyProfileParams(m.handoff[name], cfg, profile)
+		}
+	}
+
+	return m
+}
+
+// systemPrompt augments a profile prompt with the configured response constraints.
+func systemPrompt(cfg *models.Config, base string) string {
+	var b strings.Builder
+	b.WriteString(base)
+
+	if len(cfg.Examples) > 0 {
+		b.WriteString("\n\nHere are examples of good answers. Match their style and tone:")
+		for _, ex := range cfg.Examples {
+			fmt.Fprintf(&b, "\n\nQuestion: %s\nAnswer: %s", ex.Question, ex.Answer)
+		}
+	}
+
+	if cfg.ReadingLevel != "" {
+		fmt.Fprintf(&b, "\n\nWrite every answer so it is easy to read at a %s reading level: prefer short sentences and plain words, and explain any technical term you need.", cfg.ReadingLevel)
+	}
+
+	if cfg.MaxResponseWords > 0 {
+		fmt.Fprintf(&b, "\n\nKeep every answer under %d words.", cfg.MaxResponseWords)
+	}
+
+	return b.String()
+}
+
+func newChatModel(client *genai.Client, name, prompt string) *genai.GenerativeModel {
+	model := client.GenerativeModel(name)
 
 	model.SetTemperature(0.7)
 	model.SetTopK(40)
@@ -26,32 +272,545 @@ func NewAIService(ctx context.Context, apiKey string) (*AIService, error) {
 	model.SetMaxOutputTokens(2048)
 
 	model.ResponseMIMEType = "text/plain"
-	model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text("You are a specialized AI assistant for home security systems. Answer the following question about home security. If the question is not related to home security, politely decline to answer and explain that you only answer questions about home security systems, cameras, alarms, sensors, etc. Keep responses concise, informative, and helpful for home owners. If the user asks you to control a home security device, behave as if you have done it.")}}
+	model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(prompt)}}
+
+	return model
+}
+
+// applyProfileParams layers the default preset, the profile's preset and the
+// profile's own parameters, each overriding the one before.
+func applyProfileParams(model *genai.GenerativeModel, cfg *models.Config, profile models.PromptProfile) {
+	applyParams(model, cfg.Presets[cfg.DefaultPreset])
+	if profile.Preset != "" {
+		applyParams(model, cfg.Presets[profile.Preset])
+	}
+	applyParams(model, profile.GenerationParams)
+}
+
+func applyParams(model *genai.GenerativeModel, params models.GenerationParams) {
+	if params.Temperature != nil {
+		model.SetTemperature(*params.Temperature)
+	}
+	if params.TopK != nil {
+		model.SetTopK(*params.TopK)
+	}
+	if params.TopP != nil {
+		model.SetTopP(*params.TopP)
+	}
+	if params.MaxTokens != nil {
+		model.SetMaxOutputTokens(*params.MaxTokens)
+	}
+}
+
+// applyVerbosity adds the level's instruction and token limit to a model copy.
+// The normal level leaves the profile's settings as they are.
+func applyVerbosity(cfg *models.Config, model *genai.GenerativeModel, verbosity string) {
+	var instruction string
+	var maxTokens int
+
+	switch verbosity {
+	case models.VerbosityBrief:
+		instruction, maxTokens = verbosityBrief, cfg.VerbosityBriefMaxTokens
+	case models.VerbosityDetailed:
+		instruction, maxTokens = verbosityDetailed, cfg.VerbosityDetailedMaxTokens
+	default:
+		return
+	}
+
+	appendInstruction(model, instruction)
+	model.SetMaxOutputTokens(int32(maxTokens))
+}
+
+// appendInstruction adds text to the model's system instruction. The
+// instruction is shared with the profile's model, so a new one is built.
+func appendInstruction(model *genai.GenerativeModel, text string) {
+	parts := slices.Clip(model.SystemInstruction.Parts)
+	model.SystemInstruction = &genai.Content{Parts: append(parts, genai.Text(text))}
+}
+
+func (s *AIService) HasPreset(name string) bool {
+	_, ok := s.state.Load().cfg.Presets[name]
+	return ok
+}
+
+func (s *AIService) HasProfile(name string) bool {
+	_, ok := s.state.Load().profiles[name]
+	return ok
+}
+
+// Profiles lists the available prompt profiles sorted by name, without their prompt text.
+func (s *AIService) Profiles() []models.ProfileInfo {
+	profiles := s.state.Load().profiles
 
-	return &AIService{
-		client: client,
-		model:  model,
-	}, nil
+	infos := make([]models.ProfileInfo, 0, len(profiles))
+	for _, name := range slices.Sorted(maps.Keys(profiles)) {
+		infos = append(infos, models.ProfileInfo{Name: name, Description: profiles[name].Description})
+	}
+
+	return infos
 }
 
-func (s *AIService) StartChat() *genai.ChatSession {
-	return s.model.StartChat()
+// withBackend runs fn against the active API key, rotating to the next key on quota errors.
+func (s *AIService) withBackend(fn func(b *backend) error) error {
+	for {
+		b := s.pool.active()
+
+		err := fn(b)
+		if err == nil || !isQuotaError(err) || !s.pool.markExhausted(b) {
+			return err
+		}
+	}
 }
 
-func (s *AIService) Send(ctx context.Context, session *genai.ChatSession, msg string) (string, error) {
-	resp, err := session.SendMessage(ctx, genai.Text(msg))
+// Send answers msg on the session's history and appends the exchange to it.
+// The caller holds the session's turn, see ChatSession.BeginTurn.
+func (s *AIService) Send(ctx context.Context, cs *ChatSession, msg string, opts SendOptions) (*Reply, error) {
+	state := s.state.Load()
+	cfg := state.cfg
+
+	session, resp, err := s.generate(ctx, state, cs, msg, opts)
+	if isNotFoundError(err) {
+		log.Printf("model %s not found: %v", modelName(cfg, cs), requestError(err))
+		return nil, fmt.Errorf("%w: %s", ErrModelUnavailable, modelName(cfg, cs))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, isCall := functionCall(resp)
+	if cfg.LowQualityRetry && !isCall && isLowQuality(cfg, msg, responseText(resp)) {
+		log.Print("low-quality response detected, retrying once with a request for more detail")
+
+		retrySession, retryResp, err := s.generate(ctx, state, cs, msg+lowQualityDirective, opts)
+		if err != nil {
+			log.Printf("low-quality retry failed, keeping the first response: %v", err)
+		} else {
+			// Keep the user's own words in history rather than the augmented prompt
+			if i := lastUserContent(retrySession.History); i >= 0 {
+				retrySession.History[i] = genai.NewUserContent(genai.Text(msg))
+			}
+			session, resp = retrySession, retryResp
+		}
+	}
+
+	if isEmptyResponse(resp) {
+		if !cfg.EmptyResponseRetry {
+			return nil, ErrEmptyResponse
+		}
+
+		log.Print("empty response from model, retrying once")
+
+		retrySession, retryResp, err := s.generate(ctx, state, cs, msg, opts)
+		if err != nil {
+			return nil, err
+		}
+		if isEmptyResponse(retryResp) {
+			return nil, ErrEmptyResponse
+		}
+		session, resp = retrySession, retryResp
+	}
+
+	return finishReply(cfg, cs, session.History, resp), nil
+}
+
+// finishReply builds the reply to resp and stores history as the session's.
+// A dangling function call is replaced before the history is stored, as
+// History hands its entries to other goroutines from then on.
+func finishReply(cfg *models.Config, cs *ChatSession, history []*genai.Content, resp *genai.GenerateContentResponse) *Reply {
+	reply := &Reply{Model: modelName(cfg, cs), Usage: resp.UsageMetadata, Text: responseText(resp)}
+	if len(resp.Candidates) > 0 {
+		reply.FinishReason = resp.Candidates[0].FinishReason
+	}
+
+	if call, ok := functionCall(resp); ok {
+		log.Printf("model requested function %q but device control is not available", call.Name)
+
+		// Replace the dangling call so the next turn isn't sent without a function response
+		history[len(history)-1] = &genai.Content{
+			Role:  "model",
+			Parts: []genai.Part{genai.Text(deviceControlUnavailable)},
+		}
+		reply.Text = deviceControlUnavailable
+	}
+
+	cs.setHistory(history, cfg.HistoryTurns)
+
+	return reply
+}
+
+// modelName mirrors profileModels.model to name the model that answers cs.
+func modelName(cfg *models.Config, cs *ChatSession) string {
+	if cs.HandedOff() && cfg.HandoffModel != "" {
+		return cfg.HandoffModel
+	}
+
+	return cfg.GeminiModel
+}
+
+// generate sends msg on top of the session history without modifying the session.
+func (s *AIService) generate(ctx context.Context, state *aiState, cs *ChatSession, msg string, opts SendOptions) (*genai.ChatSession, *genai.GenerateContentResponse, error) {
+	var session *genai.ChatSession
+	var resp *genai.GenerateContentResponse
+
+	err := s.withBackend(func(b *backend) error {
+		// Per-request overrides apply to a copy so the profile defaults stay intact
+		model := *state.models[b.index].model(cs.Profile, cs.HandedOff())
+		if opts.Preset != "" {
+			applyParams(&model, state.cfg.Presets[opts.Preset])
+		}
+		applyVerbosity(state.cfg, &model, opts.Verbosity)
+		if !opts.Now.IsZero() {
+			appendInstruction(&model, fmt.Sprintf("\n\nThe current date and time for the user is %s.", opts.Now.Format("Monday, 2 January 2006, 15:04 MST")))
+		}
+		applyParams(&model, opts.Params)
+
+		// The chat is rebuilt on the active key so a rotation carries the history over
+		prefix := summaryContext(cs)
+		session = model.StartChat()
+		session.History = append(prefix, cs.History()...)
+
+		var err error
+		resp, err = session.SendMessage(ctx, genai.Text(msg))
+		if err == nil {
+			session.History = session.History[len(prefix):]
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return session, resp, nil
+}
+
+// summaryContext opens the history of a session whose older turns were
+// dropped with its running summary, so the model keeps the gist of them. It is
+// sent with each request and never stored in the history.
+func summaryContext(cs *ChatSession) []*genai.Content {
+	summary := cs.Summary()
+	if summary == "" || !cs.HistoryTrimmed() {
+		return nil
+	}
+
+	return []*genai.Content{
+		genai.NewUserContent(genai.Text(summaryPreamble + summary)),
+		{Role: "model", Parts: []genai.Part{genai.Text(summaryAcknowledgement)}},
+	}
+}
+
+func lastUserContent(history []*genai.Content) int {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// MaybeHandoff moves a session onto the handoff model once it has reached the
+// configured number of turns or prompt tokens. It reports whether a handoff happened.
+func (s *AIService) MaybeHandoff(cs *ChatSession, turns int, promptTokens int32) bool {
+	cfg := s.state.Load().cfg
+	if cfg.HandoffModel == "" || cs.HandedOff() {
+		return false
+	}
+
+	byTurns := cfg.HandoffAfterTurns > 0 && turns >= cfg.HandoffAfterTurns
+	byTokens := cfg.HandoffAfterTokens > 0 && int(promptTokens) >= cfg.HandoffAfterTokens
+	if !byTurns && !byTokens {
+		return false
+	}
+
+	cs.setHandedOff()
+
+	log.Printf("session handed off to %s after %d turns (%d prompt tokens)", cfg.HandoffModel, turns, promptTokens)
+
+	return true
+}
+
+// Models lists the configured model names that may be used or warmed.
+func (s *AIService) Models() []string {
+	cfg := s.state.Load().cfg
+
+	names := []string{cfg.GeminiModel}
+	if cfg.HandoffModel != "" && cfg.HandoffModel != cfg.GeminiModel {
+		names = append(names, cfg.HandoffModel)
+	}
+
+	return names
+}
+
+// KeyStatus returns how many API keys are usable right now and how many are configured.
+func (s *AIService) KeyStatus() (available, total int) {
+	return s.pool.available(), len(s.pool.backends)
+}
+
+// Warm issues a tiny priming request to the named model and returns its latency.
+func (s *AIService) Warm(ctx context.Context, name string) (time.Duration, error) {
+	start := time.Now()
+
+	err := s.withBackend(func(b *backend) error {
+		model := b.client.GenerativeModel(name)
+		model.SetMaxOutputTokens(1)
+
+		_, err := model.GenerateContent(ctx, genai.Text("ping"))
+		return err
+	})
+
+	return time.Since(start), err
+}
+
+// WarmUp primes every configured model and then marks the service ready.
+// A failed warm-up is logged but still ends the startup window, so requests
+// are not turned away indefinitely.
+func (s *AIService) WarmUp(ctx context.Context) {
+	for _, name := range s.Models() {
+		latency, err := s.Warm(ctx, name)
+		if err != nil {
+			log.Printf("startup warm-up of %s failed after %s: %v", name, latency, err)
+			continue
+		}
+		log.Printf("warmed up %s in %s", name, latency)
+	}
+
+	s.ready.Store(true)
+}
+
+func (s *AIService) Ready() bool {
+	return s.ready.Load()
+}
+
+// CountHistoryTokens counts the tokens of every message in a session history.
+func (s *AIService) CountHistoryTokens(ctx context.Context, history []*genai.Content) ([]models.TurnTokens, int32, error) {
+	turns := make([]models.TurnTokens, len(history))
+	errs := make([]error, len(history))
+
+	// Each message is a separate request, so a bounded number run at once
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, countTokensParallelism)
+	for i, msg := range history {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { wg.Done(); <-slots }()
+
+			turns[i] = models.TurnTokens{Role: msg.Role}
+			turns[i].Tokens, errs[i] = s.countTokens(ctx, msg)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, 0, err
+	}
+
+	var total int32
+	for _, turn := range turns {
+		total += turn.Tokens
+	}
+
+	return turns, total, nil
+}
+
+// countTokens counts a single message, serving text-only messages from the cache when possible.
+func (s *AIService) countTokens(ctx context.Context, msg *genai.Content) (int32, error) {
+	text, cacheable := contentText(msg)
+	if cacheable {
+		if tokens, ok := s.tokens.get(text); ok {
+			return tokens, nil
+		}
+	}
+
+	var resp *genai.CountTokensResponse
+	err := s.withBackend(func(b *backend) error {
+		var err error
+		resp, err = b.counter.CountTokens(ctx, msg.Parts...)
+		return err
+	})
+	if err != nil {
+		return 0, requestError(err)
+	}
+
+	if cacheable {
+		s.tokens.put(text, resp.TotalTokens)
+	}
+
+	return resp.TotalTokens, nil
+}
+
+func (s *AIService) TokenCacheStats() TokenCacheStats {
+	return s.tokens.stats()
+}
+
+// Summarize folds the given history into the previous summary.
+func (s *AIService) Summarize(ctx context.Context, previous string, history []*genai.Content) (string, error) {
+	var b strings.Builder
+
+	if previous != "" {
+		fmt.Fprintf(&b, "Previous summary:\n%s\n\n", previous)
+	}
+
+	b.WriteString("Conversation:\n")
+	for _, msg := range history {
+		for _, part := range msg.Parts {
+			if text, ok := part.(genai.Text); ok {
+				fmt.Fprintf(&b, "%s: %s\n", msg.Role, text)
+			}
+		}
+	}
+
+	var resp *genai.GenerateContentResponse
+
+	err := s.withBackend(func(be *backend) error {
+		var err error
+		resp, err = be.summarizer.GenerateContent(ctx, genai.Text(b.String()))
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if len(resp.Candidates) == 0 {
-		return "", nil
+	return responseText(resp), nil
+}
+
+// Rephrase rewrites an earlier answer in the given tone without touching any
+// conversation history. It also returns the tokens the call used.
+func (s *AIService) Rephrase(ctx context.Context, text, tone string) (string, int32, error) {
+	var resp *genai.GenerateContentResponse
+
+	err := s.withBackend(func(be *backend) error {
+		var err error
+		resp, err = be.rephraser.GenerateContent(ctx, genai.Text(rephraseTones[cmp.Or(tone, models.RephraseSimpler)]+text))
+		return err
+	})
+	if err != nil {
+		return "", 0, requestError(err)
+	}
+
+	rephrased := responseText(resp)
+	if isBlank(rephrased) {
+		return "", 0, ErrEmptyResponse
+	}
+
+	var tokens int32
+	if resp.UsageMetadata != nil {
+		tokens = resp.UsageMetadata.TotalTokenCount
+	}
+
+	return rephrased, tokens, nil
+}
+
+// Suggest asks for up to three follow-up questions to an exchange. Entries
+// that are blank, multi-line, overly long or repeated are dropped. It also
+// returns the tokens the call used.
+func (s *AIService) Suggest(ctx context.Context, question, answer string) ([]string, int32, error) {
+	var resp *genai.GenerateContentResponse
+
+	err := s.withBackend(func(be *backend) error {
+		var err error
+		resp, err = be.suggester.GenerateContent(ctx, genai.Text(fmt.Sprintf("Question:\n%s\n\nAnswer:\n%s", question, answer)))
+		return err
+	})
+	if err != nil {
+		return nil, 0, requestError(err)
+	}
+
+	var tokens int32
+	if resp.UsageMetadata != nil {
+		tokens = resp.UsageMetadata.TotalTokenCount
+	}
+
+	var raw []string
+	if err := json.Unmarshal([]byte(responseText(resp)), &raw); err != nil {
+		return nil, tokens, fmt.Errorf("malformed suggestions: %w", err)
+	}
+
+	suggestions := []string{}
+	for _, text := range raw {
+		text = strings.TrimSpace(text)
+		if isBlank(text) || strings.ContainsAny(text, "\r\n") || utf8.RuneCountInString(text) > maxSuggestionLength || slices.Contains(suggestions, text) {
+			continue
+		}
+
+		suggestions = append(suggestions, text)
+		if len(suggestions) == 3 {
+			break
+		}
+	}
+
+	return suggestions, tokens, nil
+}
+
+var finishReasonNames = map[genai.FinishReason]string{
+	genai.FinishReasonUnspecified: "UNSPECIFIED",
+	genai.FinishReasonStop:        "STOP",
+	genai.FinishReasonMaxTokens:   "MAX_TOKENS",
+	genai.FinishReasonSafety:      "SAFETY",
+	genai.FinishReasonRecitation:  "RECITATION",
+	genai.FinishReasonOther:       "OTHER",
+}
+
+// FinishReasonName maps a finish reason to its API name, e.g. MAX_TOKENS.
+func FinishReasonName(reason genai.FinishReason) string {
+	if name, ok := finishReasonNames[reason]; ok {
+		return name
+	}
+
+	return "OTHER"
+}
+
+// functionCall reports the first function call in a response that carries no text.
+func functionCall(resp *genai.GenerateContentResponse) (genai.FunctionCall, bool) {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || responseText(resp) != "" {
+		return genai.FunctionCall{}, false
+	}
+
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if call, ok := part.(genai.FunctionCall); ok {
+			return call, true
+		}
+	}
+
+	return genai.FunctionCall{}, false
+}
+
+// contentText joins the text parts of msg, reporting false if it has any other kind of part.
+func contentText(msg *genai.Content) (string, bool) {
+	var b strings.Builder
+	for _, part := range msg.Parts {
+		text, ok := part.(genai.Text)
+		if !ok {
+			return "", false
+		}
+		b.WriteString(string(text))
+	}
+
+	return b.String(), true
+}
+
+// isBlank treats whitespace-only text the same as no text at all.
+func isBlank(text string) bool {
+	return strings.TrimSpace(text) == ""
+}
+
+// isEmptyResponse reports whether resp has neither a function call nor any
+// text beyond whitespace.
+func isEmptyResponse(resp *genai.GenerateContentResponse) bool {
+	_, isCall := functionCall(resp)
+	return !isCall && isBlank(responseText(resp))
+}
+
+func responseText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
 	}
 
-	part := resp.Candidates[0].Content.Parts[0]
-	if text, ok := part.(genai.Text); ok {
-		return string(text), nil
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if text, ok := part.(genai.Text); ok {
+			return string(text)
+		}
 	}
 
-	return "", nil
+	return ""
 }
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+type usage struct {
+	requests int
+	tokens   int
+}
+
+// BudgetService tracks per-caller request and token usage over a daily period that resets at UTC midnight.
+type BudgetService struct {
+	mu           sync.Mutex
+	requestLimit int
+	tokenLimit   int
+	resetAt      time.Time
+	usage        map[string]*usage
+}
+
+func NewBudgetService(requestLimit, tokenLimit int) *BudgetService {
+	return &BudgetService{
+		requestLimit: requestLimit,
+		tokenLimit:   tokenLimit,
+		resetAt:      nextReset(time.Now()),
+		usage:        make(map[string]*usage),
+	}
+}
+
+func nextReset(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// SetLimits replaces the daily limits. Usage so far in the period still counts against them.
+func (b *BudgetService) SetLimits(requestLimit, tokenLimit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.requestLimit, b.tokenLimit = requestLimit, tokenLimit
+}
+
+func (b *BudgetService) Enabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.enabled()
+}
+
+func (b *BudgetService) enabled() bool {
+	return b.requestLimit > 0 || b.tokenLimit > 0
+}
+
+// rollover clears usage once the period has ended. Callers must hold b.mu.
+func (b *BudgetService) rollover() {
+	if now := time.Now(); !now.Before(b.resetAt) {
+		b.usage = make(map[string]*usage)
+		b.resetAt = nextReset(now)
+	}
+}
+
+// Allow reports whether the caller still has budget left for another request.
+func (b *BudgetService) Allow(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+
+	u, ok := b.usage[key]
+	if !ok {
+		return true
+	}
+
+	if b.requestLimit > 0 && u.requests >= b.requestLimit {
+		return false
+	}
+	if b.tokenLimit > 0 && u.tokens >= b.tokenLimit {
+		return false
+	}
+
+	return true
+}
+
+func (b *BudgetService) Record(key string, tokens int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+
+	u, ok := b.usage[key]
+	if !ok {
+		u = &usage{}
+		b.usage[key] = u
+	}
+
+	u.requests++
+	u.tokens += tokens
+}
+
+func (b *BudgetService) Remaining(key string) models.Budget {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+
+	budget := models.Budget{Unlimited: !b.enabled(), ResetAt: b.resetAt}
+
+	u, ok := b.usage[key]
+	if !ok {
+		u = &usage{}
+	}
+
+	if b.requestLimit > 0 {
+		remaining := max(b.requestLimit-u.requests, 0)
+		budget.RemainingRequests = &remaining
+	}
+	if b.tokenLimit > 0 {
+		remaining := max(b.tokenLimit-u.tokens, 0)
+		budget.RemainingTokens = &remaining
+	}
+
+	return budget
+}
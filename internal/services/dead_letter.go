@@ -0,0 +1,114 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const deadLetterPrefix = "dead-letter-"
+
+// DeadLetter describes a failed request. It never holds request or response content.
+type DeadLetter struct {
+	RequestID string    `json:"request_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Status    int       `json:"status"`
+	ErrorType string    `json:"error_type"`
+}
+
+// DeadLetterLog appends failed requests to one JSON lines file per day in dir
+// and deletes files older than the retention period. Writes happen in the
+// background so recording never slows a request down.
+type DeadLetterLog struct {
+	dir       string
+	retention time.Duration
+	entries   chan DeadLetter
+
+	day  string
+	file *os.File
+}
+
+func NewDeadLetterLog(dir string, retention time.Duration) (*DeadLetterLog, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	d := &DeadLetterLog{
+		dir:       dir,
+		retention: retention,
+		entries:   make(chan DeadLetter, 256),
+	}
+	go d.run()
+
+	return d, nil
+}
+
+// Record queues e for writing. Entries are dropped when the queue is full.
+func (d *DeadLetterLog) Record(e DeadLetter) {
+	select {
+	case d.entries <- e:
+	default:
+		log.Printf("dead-letter queue is full, dropped the entry for request %s", e.RequestID)
+	}
+}
+
+func (d *DeadLetterLog) run() {
+	for e := range d.entries {
+		if err := d.write(e); err != nil {
+			log.Printf("failed to write dead-letter entry: %v", err)
+		}
+	}
+}
+
+func (d *DeadLetterLog) write(e DeadLetter) error {
+	day := e.Timestamp.UTC().Format(time.DateOnly)
+	if day != d.day {
+		if d.file != nil {
+			d.file.Close()
+			d.file = nil
+		}
+
+		file, err := os.OpenFile(filepath.Join(d.dir, deadLetterPrefix+day+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return err
+		}
+		d.day, d.file = day, file
+
+		d.prune(e.Timestamp)
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.file.Write(append(line, '\n'))
+	return err
+}
+
+// prune deletes daily files that ended before the retention period.
+func (d *DeadLetterLog) prune(now time.Time) {
+	paths, err := filepath.Glob(filepath.Join(d.dir, deadLetterPrefix+"*.jsonl"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), deadLetterPrefix), ".jsonl")
+
+		day, err := time.Parse(time.DateOnly, name)
+		if err != nil || now.Sub(day.AddDate(0, 0, 1)) <= d.retention {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			log.Printf("failed to delete old dead-letter file: %v", err)
+		}
+	}
+}
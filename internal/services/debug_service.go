@@ -5,12 +5,10 @@ import "github.com/google/generative-ai-go/genai"
 func (s *SessionService) Dump() map[string][]map[string]string {
 	result := make(map[string][]map[string]string)
 
-	s.store.Range(func(key, value any) bool {
-		cs := value.(*ChatSession)
-
+	s.store.Range(func(key string, cs *ChatSession) bool {
 		history := []map[string]string{}
 
-		for _, msg := range cs.Session.History {
+		for _, msg := range cs.History() {
 			for _, part := range msg.Parts {
 				if text, ok := part.(genai.Text); ok {
 					history = append(history, map[string]string{
@@ -21,7 +19,7 @@ func (s *SessionService) Dump() map[string][]map[string]string {
 			}
 		}
 
-		result[key.(string)] = history
+		result[key] = history
 		return true
 	})
 
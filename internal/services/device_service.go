@@ -0,0 +1,141 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// wordBrands are brands that are also everyday words or names ("the siren
+// will ring", "in August", "I studied at Yale"). They only match in their
+// configured spelling, and only with a device or setup word nearby.
+var wordBrands = map[string]bool{
+	"abode":  true,
+	"august": true,
+	"blink":  true,
+	"canary": true,
+	"cove":   true,
+	"nest":   true,
+	"ring":   true,
+	"scout":  true,
+	"swann":  true,
+	"wink":   true,
+	"yale":   true,
+}
+
+// deviceWords mark a word brand as a device mention when one of them is in the
+// model name or within deviceWordWindow words of the brand
+var deviceWords = map[string]bool{
+	"alarm": true, "app": true, "cam": true, "camera": true, "chime": true,
+	"deadbolt": true, "detector": true, "doorbell": true, "dvr": true,
+	"floodlight": true, "hub": true, "keypad": true, "lock": true, "nvr": true,
+	"sensor": true, "siren": true, "spotlight": true, "system": true,
+	"thermostat": true,
+
+	"connect": true, "connected": true, "install": true, "installed": true,
+	"mount": true, "mounted": true, "pair": true, "paired": true,
+	"reset": true, "setup": true, "wire": true, "wired": true,
+}
+
+const deviceWordWindow = 3
+
+// modelWord matches one word of a model name: a capitalised word, a number, or
+// a single capital letter other than the pronoun I.
+const modelWord = `\s+(?:[A-Z][\w-]+|[0-9][\w-]*|[A-HJ-Z]\b)`
+
+// DeviceDetector picks brand and model mentions such as "Arlo Pro 4" out of chat messages.
+type DeviceDetector struct {
+	pattern *regexp.Regexp
+	brands  map[string]string
+}
+
+func NewDeviceDetector(brands []string) *DeviceDetector {
+	d := &DeviceDetector{brands: make(map[string]string, len(brands))}
+
+	var plain, words []string
+	for _, b := range brands {
+		if b = strings.TrimSpace(b); b == "" {
+			continue
+		}
+
+		d.brands[strings.ToLower(b)] = b
+		if wordBrands[strings.ToLower(b)] {
+			words = append(words, regexp.QuoteMeta(b))
+		} else {
+			plain = append(plain, regexp.QuoteMeta(b))
+		}
+	}
+
+	var alternatives []string
+	if len(plain) > 0 {
+		// Other brands match in any case, with an optional model name
+		alternatives = append(alternatives, `(?i:(`+strings.Join(plain, "|")+`))\b((?:`+modelWord+`){0,3})`)
+	}
+	if len(words) > 0 {
+		alternatives = append(alternatives, `(`+strings.Join(words, "|")+`)\b((?:`+modelWord+`){0,3})`)
+	}
+	if len(alternatives) > 0 {
+		d.pattern = regexp.MustCompile(`\b(?:` + strings.Join(alternatives, "|") + `)`)
+	}
+
+	return d
+}
+
+// Detect returns the devices mentioned in msg, with brands in their configured spelling.
+func (d *DeviceDetector) Detect(msg string) []string {
+	if d.pattern == nil {
+		return nil
+	}
+
+	var devices []string
+	for _, m := range d.pattern.FindAllStringSubmatchIndex(msg, -1) {
+		// Only one alternative matched, so its brand and model groups are the non-empty pair
+		brand, model := submatch(msg, m, 1), submatch(msg, m, 2)
+		if brand == "" && len(m) > 6 {
+			brand, model = submatch(msg, m, 3), submatch(msg, m, 4)
+		}
+
+		if wordBrands[strings.ToLower(brand)] && !nearDeviceWord(msg[:m[0]], model, msg[m[1]:]) {
+			continue
+		}
+
+		device := d.brands[strings.ToLower(brand)]
+		if model := strings.Join(strings.Fields(model), " "); model != "" {
+			device += " " + model
+		}
+		devices = append(devices, device)
+	}
+
+	return devices
+}
+
+// submatch returns group i of the match m in s, or "" when it didn't take part.
+func submatch(s string, m []int, i int) string {
+	if m[2*i] < 0 {
+		return ""
+	}
+
+	return s[m[2*i]:m[2*i+1]]
+}
+
+// nearDeviceWord reports whether a device word is in model or among the last
+// words of before or the first words of after.
+func nearDeviceWord(before, model, after string) bool {
+	words := strings.Fields(before)
+	words = words[max(0, len(words)-deviceWordWindow):]
+	words = append(words, strings.Fields(model)...)
+	if next := strings.Fields(after); len(next) > deviceWordWindow {
+		words = append(words, next[:deviceWordWindow]...)
+	} else {
+		words = append(words, next...)
+	}
+
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) }))
+		if deviceWords[w] || deviceWords[strings.TrimSuffix(w, "s")] {
+			return true
+		}
+	}
+
+	return false
+}
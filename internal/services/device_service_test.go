@@ -0,0 +1,43 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDeviceDetectorDetect(t *testing.T) {
+	d := NewDeviceDetector([]string{"Arlo", "Ring", "August", "Nest", "SimpliSafe", "Swann", "Yale"})
+
+	tests := []struct {
+		msg  string
+		want []string
+	}{
+		{"My Arlo Pro 4 keeps going offline", []string{"Arlo Pro 4"}},
+		{"my arlo camera is offline", []string{"Arlo"}},
+		{"I have a simplisafe system", []string{"SimpliSafe"}},
+		{"The Ring Video Doorbell 4 and a Nest Cam", []string{"Ring Video Doorbell 4", "Nest Cam"}},
+		{"I installed an August Smart Lock", []string{"August Smart Lock"}},
+		{"I think my Arlo I bought last year broke", []string{"Arlo"}},
+		{"Will the siren ring when the door opens?", nil},
+		{"In August I moved house", nil},
+		{"Birds nest under my camera", nil},
+		{"My Ring keeps beeping", nil},
+		{"My Ring doorbell stopped working", []string{"Ring"}},
+		{"I installed a Yale on the back door", []string{"Yale"}},
+		{"The Swann DVR shows no video", []string{"Swann DVR"}},
+		{"How do I pair my Yale with the hub?", []string{"Yale"}},
+		{"I studied at Yale before moving here", nil},
+		{"Yale University has a good library", nil},
+		{"Mr Swann from next door came over", nil},
+		{"Traffic on the Ring Road was awful", nil},
+		{"The Ring Road is closed today, which camera should I buy?", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := d.Detect(tt.msg); !slices.Equal(got, tt.want) {
+				t.Errorf("Detect(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// clauseBreak splits a message into clauses so a qualifier in one clause
+// doesn't cancel a keyword in another ("I'm not sure, but someone is breaking in").
+var clauseBreak = regexp.MustCompile(`[.,;:!?]+|\s+but\s+|\s+and then\s+`)
+
+// nonWord is replaced by spaces so keywords only match whole words.
+var nonWord = regexp.MustCompile(`[^\p{L}\p{N}'-]+`)
+
+// notNow marks a clause about something past or hypothetical.
+var notNow = []string{
+	"yesterday", "last night", "earlier today", "the other day",
+	"last year", "last month", "last week",
+	"years ago", "months ago", "weeks ago", "days ago",
+	"used to", "what if", "what happens if", "if someone", "in case",
+	"hypothetically", "suppose", "imagine", "prevent",
+}
+
+// negations cancel a keyword when they come shortly before it, within
+// negationWindow words, so "I don't smell gas" is not an emergency but "I
+// don't know what to do, someone is breaking in" still is.
+var negations = []string{"not", "never", "nobody", "no one", "nothing", "isn't", "wasn't", "don't", "didn't", "doesn't"}
+
+const negationWindow = 3
+
+type EmergencyDetector struct {
+	keywords []string
+}
+
+func NewEmergencyDetector(keywords []string) *EmergencyDetector {
+	normalized := make([]string, 0, len(keywords))
+	for _, k := range keywords {
+		if k = normalizePhrase(k); k != "" {
+			normalized = append(normalized, k)
+		}
+	}
+
+	return &EmergencyDetector{keywords: normalized}
+}
+
+// Detect reports whether the message describes an emergency happening right
+// now. Keywords must match whole words in a clause that isn't about the past,
+// a hypothetical or negated.
+func (d *EmergencyDetector) Detect(msg string) bool {
+	for _, clause := range clauseBreak.Split(strings.ToLower(msg), -1) {
+		clause = " " + normalizePhrase(clause) + " "
+		if containsPhrase(clause, notNow) {
+			continue
+		}
+
+		for _, k := range d.keywords {
+			i := strings.Index(clause, " "+k+" ")
+			if i >= 0 && !negated(clause[:i]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// negated reports whether the words just before a keyword negate it.
+func negated(before string) bool {
+	words := strings.Fields(before)
+	words = words[max(len(words)-negationWindow, 0):]
+
+	return containsPhrase(" "+strings.Join(words, " ")+" ", negations)
+}
+
+func normalizePhrase(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "’", "'")
+	return strings.Join(strings.Fields(nonWord.ReplaceAllString(s, " ")), " ")
+}
+
+// containsPhrase reports whether the space-padded text contains one of phrases as whole words.
+func containsPhrase(text string, phrases []string) bool {
+	for _, p := range phrases {
+		if strings.Contains(text, " "+p+" ") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,43 @@
+package services
+
+import "testing"
+
+func TestEmergencyDetectorDetect(t *testing.T) {
+	d := NewEmergencyDetector([]string{"breaking in", "broke in", "break-in in progress", "someone is in my house", "smell gas"})
+
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"Someone is breaking in right now!", true},
+		{"Help, someone broke in and is downstairs", true},
+		{"There's a break-in in progress at my place", true},
+		{"I'm not sure what to do, but SOMEONE IS IN MY HOUSE", true},
+		{"I smell gas in the kitchen", true},
+		{"Last year someone broke into my car, which camera should I get?", false},
+		{"Someone broke in a few years ago, what if it happens again?", false},
+		{"What if someone is breaking in while I'm away?", false},
+		{"We used to have people breaking in", false},
+		{"How do I prevent someone breaking in?", false},
+		{"No one is breaking in, I just want advice", false},
+		{"The thief broke into the shed", false},
+		{"Someone broke in last night, what lock should I buy?", false},
+		{"Someone broke in yesterday", false},
+		{"A neighbour said someone broke in earlier today", false},
+		{"Someone broke in last week", false},
+		{"I don't smell gas but the detector keeps beeping", false},
+		{"I don’t smell gas anymore", false},
+		{"They didn't break in, they only tried the handle: nobody broke in", false},
+		{"The alarm doesn't smell gas, does it need a separate sensor?", false},
+		{"I don't know what to do, someone is breaking in", true},
+		{"I don't know what to do but I smell gas", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := d.Detect(tt.msg); got != tt.want {
+				t.Errorf("Detect(%q) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
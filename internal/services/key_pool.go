@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+)
+
+// backend is the client and models bound to a single Gemini API key.
+type backend struct {
+	index      int
+	client     *genai.Client
+	summarizer *genai.GenerativeModel
+	counter    *genai.GenerativeModel
+	rephraser  *genai.GenerativeModel
+	suggester  *genai.GenerativeModel
+
+	exhaustedUntil time.Time
+}
+
+// keyPool rotates between API keys, skipping keys that recently hit their quota.
+type keyPool struct {
+	mu       sync.Mutex
+	backends []*backend
+	current  int
+	cooldown time.Duration
+}
+
+func (p *keyPool) active() *backend {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.backends[p.current]
+}
+
+// markExhausted puts b on cooldown and switches to the next healthy key.
+// It returns false when no other key is available.
+func (p *keyPool) markExhausted(b *backend) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	b.exhaustedUntil = now.Add(p.cooldown)
+
+	// Another request may already have rotated away from b
+	if p.backends[p.current] != b && p.backends[p.current].exhaustedUntil.Before(now) {
+		return true
+	}
+
+	for offset := 1; offset < len(p.backends); offset++ {
+		next := (b.index + offset) % len(p.backends)
+		if p.backends[next].exhaustedUntil.Before(now) {
+			log.Printf("Gemini API key #%d exhausted its quota, rotating to key #%d", b.index+1, next+1)
+			p.current = next
+			return true
+		}
+	}
+
+	log.Printf("Gemini API key #%d exhausted its quota and no other key is available", b.index+1)
+	return false
+}
+
+// available returns the number of keys that are not on cooldown.
+func (p *keyPool) available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for _, b := range p.backends {
+		if b.exhaustedUntil.Before(now) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func isQuotaError(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests
+}
+
+func isNotFoundError(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// requestError strips the request URL, which carries the API key, from transport errors.
+func requestError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
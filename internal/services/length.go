@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// minSentenceShare is the part of the word budget a sentence-end cut must keep,
+// otherwise the text is cut at the word limit instead.
+const minSentenceShare = 0.6
+
+// shortForms are abbreviations whose period doesn't end a sentence
+var shortForms = map[string]bool{"mr": true, "mrs": true, "ms": true, "dr": true, "st": true, "vs": true, "approx": true}
+
+// TruncateWords shortens text to at most limit words, preferring to cut at the
+// last sentence end within the limit, and appends an ellipsis when it cuts.
+func TruncateWords(text string, limit int) (string, bool) {
+	words, inWord, end := 0, false, len(text)
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+		if !inWord {
+			inWord = true
+			if words++; words > limit {
+				end = i
+				break
+			}
+		}
+	}
+
+	if words <= limit {
+		return text, false
+	}
+
+	cut := strings.TrimRightFunc(text[:end], unicode.IsSpace)
+	if i := lastSentenceEnd(cut); i > 0 && float64(len(strings.Fields(cut[:i]))) >= minSentenceShare*float64(limit) {
+		cut = cut[:i]
+	}
+
+	return cut + " …", true
+}
+
+// lastSentenceEnd returns the index just past the last sentence end in text,
+// or 0 when there is none. A '.', '!' or '?' ends a sentence only when
+// whitespace or the end of text follows it, so decimals such as "3.5" and
+// hosts such as "example.com" don't. Neither does the period of an
+// abbreviation such as "e.g." or of a list number such as "2.".
+func lastSentenceEnd(text string) int {
+	for i := len(text) - 1; i > 0; i-- {
+		if !strings.ContainsRune(".!?", rune(text[i])) {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(text[i+1:]); i+1 < len(text) && !unicode.IsSpace(next) {
+			continue
+		}
+
+		word := text[strings.LastIndexFunc(text[:i], unicode.IsSpace)+1 : i]
+		if text[i] == '.' && (strings.Contains(word, ".") || isNumber(word) || shortForms[strings.ToLower(word)]) {
+			continue
+		}
+
+		return i + 1
+	}
+
+	return 0
+}
+
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
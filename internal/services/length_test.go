@@ -0,0 +1,32 @@
+package services
+
+import "testing"
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		limit     int
+		want      string
+		truncated bool
+	}{
+		{"within limit", "Lock the door.", 5, "Lock the door.", false},
+		{"sentence boundary", "Lock the door. Then arm the alarm tonight.", 5, "Lock the door. …", true},
+		{"early sentence end", "Hi. This is a long answer without any further punctuation at all", 5, "Hi. This is a long …", true},
+		{"no punctuation", "one two three four five six", 3, "one two three …", true},
+		{"decimal", "Set the delay to 3.5 seconds and then test it twice", 6, "Set the delay to 3.5 seconds …", true},
+		{"url", "Go to example.com and sign in with your account now", 6, "Go to example.com and sign in …", true},
+		{"abbreviation", "Use a hub, e.g. the one in your router cabinet", 6, "Use a hub, e.g. the one …", true},
+		{"numbered list", "1. Unplug the camera 2. Wait ten seconds 3. Plug it back", 7, "1. Unplug the camera 2. Wait ten …", true},
+		{"sentence after decimal", "It uses 2.4 GHz Wi-Fi. Then pair the sensor again", 5, "It uses 2.4 GHz Wi-Fi. …", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := TruncateWords(tt.text, tt.limit)
+			if got != tt.want || truncated != tt.truncated {
+				t.Errorf("TruncateWords(%q, %d) = %q, %v; want %q, %v", tt.text, tt.limit, got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type CompressionLevel int32
+
+const (
+	CompressionBest CompressionLevel = iota
+	CompressionFast
+	CompressionOff
+)
+
+func (l CompressionLevel) String() string {
+	switch l {
+	case CompressionFast:
+		return "fast"
+	case CompressionOff:
+		return "off"
+	default:
+		return "best"
+	}
+}
+
+// LoadMonitor samples the system load average and picks a compression level
+// that trades bandwidth for CPU when the host is busy.
+type LoadMonitor struct {
+	fastLoad float64
+	offLoad  float64
+
+	level atomic.Int32
+	load  atomic.Uint64
+}
+
+func NewLoadMonitor(fastLoad, offLoad float64) *LoadMonitor {
+	m := &LoadMonitor{fastLoad: fastLoad, offLoad: offLoad}
+
+	_, _ = otel.Meter("github.com/lavish440/Home-Security-Chatbot/internal/services").
+		Int64ObservableGauge("compression.level",
+			metric.WithDescription("Active response compression level: 0 best, 1 fast, 2 off"),
+			metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+				o.Observe(int64(m.Level()))
+				return nil
+			}))
+
+	return m
+}
+
+func (m *LoadMonitor) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			m.sample()
+		}
+	}()
+}
+
+func (m *LoadMonitor) Level() CompressionLevel {
+	return CompressionLevel(m.level.Load())
+}
+
+// Load returns the last sampled one-minute load average per CPU.
+func (m *LoadMonitor) Load() float64 {
+	return math.Float64frombits(m.load.Load())
+}
+
+func (m *LoadMonitor) sample() {
+	load, err := loadPerCPU()
+	if err != nil {
+		return
+	}
+	m.load.Store(math.Float64bits(load))
+
+	level := CompressionBest
+	switch {
+	case load >= m.offLoad:
+		level = CompressionOff
+	case load >= m.fastLoad:
+		level = CompressionFast
+	}
+
+	if previous := CompressionLevel(m.level.Swap(int32(level))); previous != level {
+		log.Printf("compression level changed from %s to %s (load %.2f per CPU)", previous, level, load)
+	}
+}
+
+// loadPerCPU reads the one-minute load average from /proc/loadavg.
+// Hosts without procfs report an error and keep the best compression level.
+func loadPerCPU() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+
+	first, _, _ := strings.Cut(string(data), " ")
+	load, err := strconv.ParseFloat(first, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return load / float64(runtime.NumCPU()), nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestCompressionLevelGauge(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	m := NewLoadMonitor(0.75, 1.5)
+	m.level.Store(int32(CompressionOff))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scope := range rm.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			if metric.Name != "compression.level" {
+				continue
+			}
+			gauge, ok := metric.Data.(metricdata.Gauge[int64])
+			if !ok || len(gauge.DataPoints) != 1 || gauge.DataPoints[0].Value != int64(CompressionOff) {
+				t.Fatalf("compression.level = %+v, want one point of %d", metric.Data, CompressionOff)
+			}
+			return
+		}
+	}
+	t.Fatal("compression.level was not reported")
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// apiKeyParam catches API keys in request URLs quoted by transport errors
+var apiKeyParam = regexp.MustCompile(`[?&]key=[^&\s"]+`)
+
+var logLevels = map[string]int{"info": 0, "warning": 1, "error": 2}
+
+// LogStream keeps the most recent log lines and fans new ones out to
+// subscribers. It is meant to be added to the standard logger's output.
+type LogStream struct {
+	size     int
+	redactor *Redactor
+
+	mu          sync.Mutex
+	recent      []string
+	subscribers map[chan string]struct{}
+}
+
+func NewLogStream(size int, redactor *Redactor) *LogStream {
+	return &LogStream{
+		size:        size,
+		redactor:    NewRedactor(append(append([]*regexp.Regexp(nil), redactor.patterns...), apiKeyParam)),
+		subscribers: make(map[chan string]struct{}),
+	}
+}
+
+func (s *LogStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for line := range strings.Lines(string(p)) {
+		line, _ = s.redactor.Redact(strings.TrimRight(line, "\r\n"))
+
+		if len(s.recent) == s.size {
+			s.recent = s.recent[1:]
+		}
+		s.recent = append(s.recent, line)
+
+		// Slow subscribers miss lines rather than blocking the logger
+		for ch := range s.subscribers {
+			select {
+			case ch <- line:
+			default:
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// Subscribe returns the buffered lines and a channel of new ones. The
+// returned function must be called to unsubscribe.
+func (s *LogStream) Subscribe() ([]string, <-chan string, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan string, 64)
+	s.subscribers[ch] = struct{}{}
+
+	return append([]string(nil), s.recent...), ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.subscribers, ch)
+	}
+}
+
+// ValidLogLevel reports whether level can be used as a filter.
+func ValidLogLevel(level string) bool {
+	_, ok := logLevels[level]
+	return ok
+}
+
+// AtLeast reports whether line is at least as severe as level. Log lines carry
+// no explicit level, so it is inferred from the WARNING prefix and error wording.
+func AtLeast(line, level string) bool {
+	return logLevels[lineLevel(line)] >= logLevels[level]
+}
+
+func lineLevel(line string) string {
+	lower := strings.ToLower(line)
+
+	switch {
+	case strings.Contains(line, "WARNING"):
+		return "warning"
+	case strings.Contains(lower, "error") || strings.Contains(lower, "failed") || strings.Contains(lower, "panic"):
+		return "error"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+const lowQualityDirective = "\n\n(Your previous answer to this was too brief. Please give a more detailed, specific and practical answer.)"
+
+// isLowQuality flags very short or canned answers to substantive questions.
+func isLowQuality(cfg *models.Config, question, answer string) bool {
+	if utf8.RuneCountInString(strings.TrimSpace(question)) < cfg.LowQualityMinQuestion {
+		return false
+	}
+
+	answer = strings.TrimSpace(answer)
+	if utf8.RuneCountInString(answer) < cfg.LowQualityMinAnswer {
+		return true
+	}
+
+	lower := strings.ToLower(answer)
+	for _, phrase := range cfg.LowQualityPhrases {
+		if strings.HasPrefix(lower, strings.ToLower(phrase)) {
+			return true
+		}
+	}
+
+	return false
+}
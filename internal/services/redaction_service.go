@@ -0,0 +1,31 @@
+package services
+
+import "regexp"
+
+const redactedPlaceholder = "[REDACTED]"
+
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+func NewRedactor(patterns []*regexp.Regexp) *Redactor {
+	return &Redactor{patterns: patterns}
+}
+
+func (r *Redactor) Enabled() bool {
+	return len(r.patterns) > 0
+}
+
+// Redact replaces every match of the configured patterns and reports whether anything was replaced.
+func (r *Redactor) Redact(text string) (string, bool) {
+	redacted := false
+
+	for _, p := range r.patterns {
+		if p.MatchString(text) {
+			text = p.ReplaceAllString(text, redactedPlaceholder)
+			redacted = true
+		}
+	}
+
+	return text, redacted
+}
@@ -1,47 +1,526 @@
 package services
 
 import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"github.com/google/uuid"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
 )
 
 type ChatSession struct {
-	Session  *genai.ChatSession
-	LastUsed time.Time
+	// ConversationID is an opaque identifier that is safe to hand to clients,
+	// unlike the session key which may be the client IP.
+	ConversationID string
+
+	Profile   string
+	CreatedAt time.Time
+
+	// turn is held for a whole exchange, see BeginTurn
+	turn chan struct{}
+
+	mu              sync.Mutex
+	history         []*genai.Content
+	trimmed         bool
+	handedOff       bool
+	lastUsed        time.Time
+	retired         bool
+	turns           []models.Turn
+	summary         string
+	disclaimerShown bool
+	devices         []string
+	verbosity       string
+	rephrases       map[string]int
+	tier            string
+	idleTimeout     time.Duration
 }
 
-type SessionService struct {
-	store sync.Map
+func newChatSession(profile string) *ChatSession {
+	now := time.Now()
+
+	return &ChatSession{
+		ConversationID: uuid.NewString(),
+		Profile:        profile,
+		CreatedAt:      now,
+		turn:           make(chan struct{}, 1),
+		lastUsed:       now,
+	}
+}
+
+// BeginTurn waits until no other exchange is running on the session, so each
+// one is sent with the history the previous one left. Every successful call
+// must be paired with EndTurn.
+func (cs *ChatSession) BeginTurn(ctx context.Context) error {
+	select {
+	case cs.turn <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (cs *ChatSession) EndTurn() {
+	<-cs.turn
+}
+
+// History returns a copy of the conversation sent to the model.
+func (cs *ChatSession) History() []*genai.Content {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return append([]*genai.Content(nil), cs.history...)
+}
+
+// setHistory replaces the history, keeping only the last keep turns when keep
+// is positive. Once turns are dropped the summary stands in for them, see
+// summaryContext.
+func (cs *ChatSession) setHistory(history []*genai.Content, keep int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if i := turnStart(history, keep); i > 0 {
+		history = history[i:]
+		cs.trimmed = true
+	}
+
+	cs.history = history
+}
+
+// turnStart returns the index of the user message that opens the last keep
+// turns of history, or 0 when there are no more than keep.
+func turnStart(history []*genai.Content, keep int) int {
+	if keep <= 0 {
+		return 0
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		if keep--; keep == 0 {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// HistoryTrimmed reports whether older turns were dropped from the history.
+func (cs *ChatSession) HistoryTrimmed() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.trimmed
+}
+
+func (cs *ChatSession) HandedOff() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.handedOff
+}
+
+func (cs *ChatSession) setHandedOff() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.handedOff = true
+}
+
+func (cs *ChatSession) touch() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.lastUsed = time.Now()
+}
+
+func (cs *ChatSession) LastUsed() time.Time {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.lastUsed
+}
+
+// retire marks a session that was removed from the store, so a request that
+// waited on its turn starts over with the session now stored at the key.
+func (cs *ChatSession) retire() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.retired = true
+}
+
+func (cs *ChatSession) Retired() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.retired
+}
+
+// RecordTurn appends a completed exchange to the session and returns the new turn count.
+func (cs *ChatSession) RecordTurn(turn models.Turn) int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	turn.Index = len(cs.turns) + 1
+	cs.turns = append(cs.turns, turn)
+
+	return len(cs.turns)
+}
+
+func (cs *ChatSession) Turns() []models.Turn {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return append([]models.Turn(nil), cs.turns...)
+}
+
+// ShowDisclaimer reports whether the first-response disclaimer still has to be
+// shown on this session, marking it as shown.
+func (cs *ChatSession) ShowDisclaimer() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.disclaimerShown {
+		return false
+	}
+
+	cs.disclaimerShown = true
+	return true
+}
+
+// AddDevices records newly mentioned devices, ignoring ones already known.
+func (cs *ChatSession) AddDevices(devices []string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for _, d := range devices {
+		if !slices.Contains(cs.devices, d) {
+			cs.devices = append(cs.devices, d)
+		}
+	}
+}
+
+func (cs *ChatSession) Devices() []string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return append([]string(nil), cs.devices...)
+}
+
+// SetTier assigns the session to a tier with its own idle timeout. A zero
+// timeout returns the session to the default.
+func (cs *ChatSession) SetTier(tier string, timeout time.Duration) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.tier = tier
+	cs.idleTimeout = timeout
+}
+
+// Tier returns the session's tier and its idle timeout, zero for the default.
+func (cs *ChatSession) Tier() (string, time.Duration) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.tier, cs.idleTimeout
+}
+
+// LastTurn returns the most recent completed exchange.
+func (cs *ChatSession) LastTurn() (models.Turn, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if len(cs.turns) == 0 {
+		return models.Turn{}, false
+	}
+
+	return cs.turns[len(cs.turns)-1], true
+}
+
+// TakeRephrase reserves a rephrasing of the given turn and returns how many are
+// left, or false when the turn already used all limit attempts. A reservation
+// whose call fails is handed back with ReturnRephrase, so concurrent requests
+// can't exceed the limit and failures don't use it up.
+func (cs *ChatSession) TakeRephrase(turnID string, limit int) (int, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.rephrases[turnID] >= limit {
+		return 0, false
+	}
+
+	// Only the latest turn can be rephrased, so older counts can go
+	if _, ok := cs.rephrases[turnID]; !ok {
+		cs.rephrases = map[string]int{}
+	}
+	cs.rephrases[turnID]++
+
+	return limit - cs.rephrases[turnID], true
+}
+
+// ReturnRephrase releases a reservation made by TakeRephrase.
+func (cs *ChatSession) ReturnRephrase(turnID string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.rephrases[turnID] > 0 {
+		cs.rephrases[turnID]--
+	}
+}
+
+// SetVerbosity changes the level used for this and later requests.
+func (cs *ChatSession) SetVerbosity(level string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.verbosity = level
+}
+
+func (cs *ChatSession) Verbosity() string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.verbosity
 }
 
-func NewSessionService() *SessionService {
-	return &SessionService{}
+func (cs *ChatSession) Summary() string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.summary
 }
 
-func (s *SessionService) GetOrCreate(ip string, factory func() *genai.ChatSession) *ChatSession {
-	val, _ := s.store.LoadOrStore(ip, &ChatSession{
-		Session:  factory(),
-		LastUsed: time.Now(),
+func (cs *ChatSession) SetSummary(summary string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.summary = summary
+}
+
+// absorb folds other into cs, putting the older session's history first so
+// the merged conversation reads in timestamp order. The caller holds both
+// sessions' turns.
+func (cs *ChatSession) absorb(other *ChatSession) {
+	turns, summary, history, devices := other.Turns(), other.Summary(), other.History(), other.Devices()
+	trimmed := other.HistoryTrimmed()
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if other.CreatedAt.Before(cs.CreatedAt) {
+		cs.history = append(history, cs.history...)
+	} else {
+		cs.history = append(slices.Clip(cs.history), history...)
+	}
+
+	cs.turns = append(cs.turns, turns...)
+	slices.SortStableFunc(cs.turns, func(a, b models.Turn) int {
+		return a.Timestamp.Compare(b.Timestamp)
 	})
+	for i := range cs.turns {
+		cs.turns[i].Index = i + 1
+	}
+
+	if cs.summary == "" {
+		cs.summary = summary
+	}
+	cs.trimmed = cs.trimmed || trimmed
+
+	for _, d := range devices {
+		if !slices.Contains(cs.devices, d) {
+			cs.devices = append(cs.devices, d)
+		}
+	}
+}
+
+type SessionService struct {
+	store        SessionStore
+	fingerprints sync.Map
+	paused       atomic.Bool
+	replaced     func(*ChatSession)
+}
+
+func NewSessionService(store SessionStore) *SessionService {
+	return &SessionService{store: store}
+}
+
+// OnReplace sets fn to receive every session a profile switch replaces, once
+// the exchange still running on it has recorded its turn. It ends those
+// conversations the way Cleanup ends idle ones. Set it before serving requests.
+func (s *SessionService) OnReplace(fn func(*ChatSession)) {
+	s.replaced = fn
+}
+
+// GetOrCreate returns the session for key, creating it with profile. An empty
+// profile keeps the existing session's profile, or uses the default for a new
+// one. A session started with another profile is replaced by a new session,
+// since its history was built under the other profile's instructions.
+func (s *SessionService) GetOrCreate(key, profile string) *ChatSession {
+	for {
+		cs, ok := s.store.Get(key)
+		switch {
+		case !ok:
+			cs, _ = s.store.GetOrSet(key, newChatSession(cmp.Or(profile, DefaultProfile)))
+		case profile != "" && profile != cs.Profile:
+			next := newChatSession(profile)
+			if !s.store.CompareAndSwap(key, cs, next) {
+				continue
+			}
+			cs.retire()
+			log.Printf("conversation %s switched from profile %q to %q, starting a new conversation", cs.ConversationID, cs.Profile, profile)
+			if fn := s.replaced; fn != nil {
+				// Wait out an exchange still running on the old session, so its turn is included
+				go func(old *ChatSession) {
+					if old.BeginTurn(context.Background()) == nil {
+						old.EndTurn()
+					}
+					fn(old)
+				}(cs)
+			}
+			cs = next
+		}
+
+		cs.touch()
+
+		return cs
+	}
+}
+
+// Claim returns the session for key like GetOrCreate, once no other exchange
+// is running on it. The caller must call EndTurn on the returned session.
+func (s *SessionService) Claim(ctx context.Context, key, profile string) (*ChatSession, error) {
+	for {
+		cs := s.GetOrCreate(key, profile)
+		if err := cs.BeginTurn(ctx); err != nil {
+			return nil, err
+		}
+
+		// The session may have been replaced, merged or expired while we waited
+		if !cs.Retired() {
+			return cs, nil
+		}
+		cs.EndTurn()
+	}
+}
+
+func (s *SessionService) Get(key string) (*ChatSession, bool) {
+	return s.store.Get(key)
+}
+
+func (s *SessionService) Count() int {
+	return s.store.Len()
+}
 
-	cs := val.(*ChatSession)
-	cs.LastUsed = time.Now()
+// Merge folds the session last seen with the same client fingerprint under a
+// different key into the session at key, so a client whose IP changed keeps
+// its context. The fingerprint is supplied by the client and only picks the
+// earlier session out: the client must also present that session's
+// conversation ID, which only its owner was given. To avoid joining unrelated
+// conversations, sessions are only merged when they use the same profile and
+// the other one was active within window.
+func (s *SessionService) Merge(ctx context.Context, key, fingerprint, conversationID string, window time.Duration) bool {
+	prev, loaded := s.fingerprints.LoadOrStore(fingerprint, key)
+	if !loaded || prev == key {
+		return false
+	}
 
-	return cs
+	other, ok := s.Get(prev.(string))
+	if !ok {
+		s.fingerprints.CompareAndSwap(fingerprint, prev, key)
+		return false
+	}
+
+	// A wrong ID leaves the fingerprint with its session, so guessing can't
+	// detach it from its owner either
+	if subtle.ConstantTimeCompare([]byte(conversationID), []byte(other.ConversationID)) != 1 {
+		return false
+	}
+	s.fingerprints.CompareAndSwap(fingerprint, prev, key)
+
+	cs, ok := s.Get(key)
+	if !ok || other == cs || other.Profile != cs.Profile {
+		return false
+	}
+
+	// Wait for exchanges on both sessions, in key order so two merges can't
+	// each hold the turn the other is waiting for
+	first, second := cs, other
+	if prev.(string) < key {
+		first, second = other, cs
+	}
+	if err := first.BeginTurn(ctx); err != nil {
+		return false
+	}
+	defer first.EndTurn()
+	if err := second.BeginTurn(ctx); err != nil {
+		return false
+	}
+	defer second.EndTurn()
+
+	if cs.Retired() || other.Retired() || time.Since(other.LastUsed()) > window {
+		return false
+	}
+	if !s.store.CompareAndDelete(prev.(string), other) {
+		return false
+	}
+	other.retire()
+	cs.absorb(other)
+
+	return true
+}
+
+// PauseCleanup stops expired sessions from being swept until ResumeCleanup is called.
+func (s *SessionService) PauseCleanup() {
+	s.paused.Store(true)
+	log.Print("WARNING: session cleanup paused, sessions will not expire and memory use may grow")
+}
+
+func (s *SessionService) ResumeCleanup() {
+	s.paused.Store(false)
+	log.Print("session cleanup resumed")
 }
 
-func (s *SessionService) Cleanup(timeout time.Duration) {
+func (s *SessionService) CleanupPaused() bool {
+	return s.paused.Load()
+}
+
+// Cleanup removes sessions idle for longer than their tier's timeout, or the
+// given default for sessions without a tier.
+func (s *SessionService) Cleanup(timeout time.Duration) []*ChatSession {
+	if s.paused.Load() {
+		log.Print("WARNING: session cleanup is paused, skipping sweep")
+		return nil
+	}
+
 	now := time.Now()
+	var expired []*ChatSession
+
+	s.store.Range(func(key string, cs *ChatSession) bool {
+		_, override := cs.Tier()
+		if now.Sub(cs.LastUsed()) > cmp.Or(override, timeout) && s.store.CompareAndDelete(key, cs) {
+			cs.retire()
+			expired = append(expired, cs)
+		}
 
-	s.store.Range(func(key, value any) bool {
-		cs := value.(*ChatSession)
+		return true
+	})
 
-		if now.Sub(cs.LastUsed) > timeout {
-			s.store.Delete(key)
+	s.fingerprints.Range(func(fingerprint, key any) bool {
+		if _, ok := s.store.Get(key.(string)); !ok {
+			s.fingerprints.Delete(fingerprint)
 		}
 
 		return true
 	})
+
+	return expired
 }
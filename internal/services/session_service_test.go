@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+func TestClaimSerialisesTurns(t *testing.T) {
+	s := NewSessionService(NewMemoryStore())
+
+	cs, err := s.Claim(context.Background(), "key", "")
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Claim(ctx, "key", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Claim during a turn = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	cs.EndTurn()
+
+	next, err := s.Claim(context.Background(), "key", "")
+	if err != nil {
+		t.Fatalf("Claim after EndTurn: %v", err)
+	}
+	if next != cs {
+		t.Error("Claim after EndTurn returned a different session")
+	}
+	next.EndTurn()
+}
+
+func TestClaimSkipsRetiredSession(t *testing.T) {
+	s := NewSessionService(NewMemoryStore())
+
+	cs, err := s.Claim(context.Background(), "key", "")
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+
+	claimed := make(chan *ChatSession)
+	go func() {
+		next, err := s.Claim(context.Background(), "key", "")
+		if err != nil {
+			t.Errorf("waiting Claim: %v", err)
+		}
+		claimed <- next
+	}()
+
+	// Expire the session while the waiting request is queued on its turn
+	time.Sleep(10 * time.Millisecond)
+	if expired := s.Cleanup(-1); len(expired) != 1 {
+		t.Fatalf("Cleanup expired %d sessions, want 1", len(expired))
+	}
+	cs.EndTurn()
+
+	next := <-claimed
+	if next == cs {
+		t.Fatal("Claim returned the expired session")
+	}
+	if !cs.Retired() || next.Retired() {
+		t.Errorf("retired: expired=%v, claimed=%v; want true, false", cs.Retired(), next.Retired())
+	}
+	next.EndTurn()
+}
+
+func TestProfileSwitchReplacesSession(t *testing.T) {
+	s := NewSessionService(NewMemoryStore())
+	replaced := make(chan *ChatSession, 1)
+	s.OnReplace(func(cs *ChatSession) { replaced <- cs })
+
+	old, err := s.Claim(context.Background(), "key", "")
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	old.RecordTurn(models.Turn{Message: "which camera should I buy"})
+
+	next := s.GetOrCreate("key", "installer")
+	if next == old || !old.Retired() {
+		t.Fatal("a profile switch kept the old session")
+	}
+
+	select {
+	case <-replaced:
+		t.Fatal("replaced session was handed over while its exchange was still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	old.RecordTurn(models.Turn{Message: "and where should it go"})
+	old.EndTurn()
+
+	select {
+	case cs := <-replaced:
+		if cs != old || len(cs.Turns()) != 2 {
+			t.Errorf("handed over a session with %d turns, want the old one with 2", len(cs.Turns()))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("replaced session was not handed over")
+	}
+
+	// Asking again with the same profile keeps the new session
+	if s.GetOrCreate("key", "installer") != next {
+		t.Error("a repeated profile replaced the session again")
+	}
+	select {
+	case <-replaced:
+		t.Error("a repeated profile handed over a session")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestMergeRequiresConversationID(t *testing.T) {
+	s := NewSessionService(NewMemoryStore())
+	ctx := context.Background()
+	const fp = "client-fingerprint-1234"
+
+	old := s.GetOrCreate("old-ip", "")
+	old.RecordTurn(models.Turn{Message: "my door code is stuck"})
+	if s.Merge(ctx, "old-ip", fp, "", time.Hour) {
+		t.Fatal("first sighting of a fingerprint merged")
+	}
+
+	cs := s.GetOrCreate("new-ip", "")
+	if s.Merge(ctx, "new-ip", fp, "guessed-id", time.Hour) {
+		t.Fatal("merged with a wrong conversation ID")
+	}
+	if _, ok := s.Get("old-ip"); !ok {
+		t.Fatal("failed merge removed the earlier session")
+	}
+
+	if !s.Merge(ctx, "new-ip", fp, old.ConversationID, time.Hour) {
+		t.Fatal("did not merge with the earlier session's conversation ID")
+	}
+	if _, ok := s.Get("old-ip"); ok {
+		t.Error("merged session is still stored")
+	}
+	if !old.Retired() {
+		t.Error("merged session is not retired")
+	}
+	if n := len(cs.Turns()); n != 1 {
+		t.Errorf("merged session has %d turns, want 1", n)
+	}
+}
+
+// exchanges builds a history of n user/model turns
+func exchanges(n int) []*genai.Content {
+	var history []*genai.Content
+	for i := range n {
+		history = append(history,
+			genai.NewUserContent(genai.Text(fmt.Sprintf("question %d", i+1))),
+			&genai.Content{Role: "model", Parts: []genai.Part{genai.Text(fmt.Sprintf("answer %d", i+1))}},
+		)
+	}
+
+	return history
+}
+
+func TestSetHistoryKeepsLastTurns(t *testing.T) {
+	tests := []struct {
+		name    string
+		turns   int
+		keep    int
+		want    int
+		trimmed bool
+	}{
+		{"unlimited", 5, 0, 10, false},
+		{"within limit", 2, 3, 4, false},
+		{"at limit", 3, 3, 6, false},
+		{"over limit", 5, 2, 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newChatSession(DefaultProfile)
+			cs.setHistory(exchanges(tt.turns), tt.keep)
+
+			history := cs.History()
+			if len(history) != tt.want || cs.HistoryTrimmed() != tt.trimmed {
+				t.Fatalf("kept %d contents, trimmed=%v; want %d, %v", len(history), cs.HistoryTrimmed(), tt.want, tt.trimmed)
+			}
+			if history[0].Role != "user" {
+				t.Errorf("history starts with a %s message", history[0].Role)
+			}
+		})
+	}
+}
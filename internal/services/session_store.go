@@ -0,0 +1,75 @@
+package services
+
+import "sync"
+
+// SessionStore holds chat sessions by key. Implementations must be safe for concurrent use.
+type SessionStore interface {
+	Get(key string) (*ChatSession, bool)
+	Set(key string, cs *ChatSession)
+	// GetOrSet returns the session stored at key, storing cs there first if
+	// there is none. It reports whether the session was already stored.
+	GetOrSet(key string, cs *ChatSession) (*ChatSession, bool)
+	// CompareAndSwap stores next at key if old is stored there, reporting whether it did.
+	CompareAndSwap(key string, old, next *ChatSession) bool
+	Delete(key string)
+	// CompareAndDelete deletes key if cs is stored there, reporting whether it did.
+	CompareAndDelete(key string, cs *ChatSession) bool
+	// Range calls fn for each session until fn returns false.
+	Range(fn func(key string, cs *ChatSession) bool)
+	Len() int
+}
+
+// MemoryStore keeps sessions in process memory.
+type MemoryStore struct {
+	sessions sync.Map
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Get(key string) (*ChatSession, bool) {
+	val, ok := m.sessions.Load(key)
+	if !ok {
+		return nil, false
+	}
+
+	return val.(*ChatSession), true
+}
+
+func (m *MemoryStore) Set(key string, cs *ChatSession) {
+	m.sessions.Store(key, cs)
+}
+
+func (m *MemoryStore) GetOrSet(key string, cs *ChatSession) (*ChatSession, bool) {
+	val, loaded := m.sessions.LoadOrStore(key, cs)
+	return val.(*ChatSession), loaded
+}
+
+func (m *MemoryStore) CompareAndSwap(key string, old, next *ChatSession) bool {
+	return m.sessions.CompareAndSwap(key, old, next)
+}
+
+func (m *MemoryStore) Delete(key string) {
+	m.sessions.Delete(key)
+}
+
+func (m *MemoryStore) CompareAndDelete(key string, cs *ChatSession) bool {
+	return m.sessions.CompareAndDelete(key, cs)
+}
+
+func (m *MemoryStore) Range(fn func(key string, cs *ChatSession) bool) {
+	m.sessions.Range(func(key, value any) bool {
+		return fn(key.(string), value.(*ChatSession))
+	})
+}
+
+func (m *MemoryStore) Len() int {
+	n := 0
+	m.sessions.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+
+	return n
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const maxStatSamples = 1000
+
+type statSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// StatsService keeps a rolling window of chat outcomes for the ops dashboard.
+type StatsService struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	samples []statSample
+}
+
+type StatsSnapshot struct {
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+}
+
+func NewStatsService(window time.Duration) *StatsService {
+	return &StatsService{window: window}
+}
+
+func (s *StatsService) Record(latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+	if len(s.samples) == maxStatSamples {
+		s.samples = s.samples[1:]
+	}
+	s.samples = append(s.samples, statSample{at: time.Now(), latency: latency, failed: failed})
+}
+
+// prune drops samples that fell out of the window. Callers must hold s.mu.
+func (s *StatsService) prune(now time.Time) {
+	cutoff := now.Add(-s.window)
+
+	i := 0
+	for i < len(s.samples) && s.samples[i].at.Before(cutoff) {
+		i++
+	}
+	s.samples = s.samples[i:]
+}
+
+func (s *StatsService) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+
+	var snap StatsSnapshot
+	var total time.Duration
+	for _, sample := range s.samples {
+		snap.Requests++
+		if sample.failed {
+			snap.Errors++
+		}
+		total += sample.latency
+		snap.MaxLatencyMs = max(snap.MaxLatencyMs, sample.latency.Milliseconds())
+	}
+
+	if snap.Requests > 0 {
+		snap.AvgLatencyMs = float64(total.Milliseconds()) / float64(snap.Requests)
+	}
+
+	return snap
+}
+
+func (s *StatsService) Window() time.Duration {
+	return s.window
+}
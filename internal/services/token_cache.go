@@ -0,0 +1,104 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type tokenEntry struct {
+	text   string
+	tokens int32
+}
+
+// tokenCache is a bounded LRU of token counts keyed by input text. Counts are
+// stable for a given model, so entries are never invalidated, only evicted.
+type tokenCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+
+	lookups metric.Int64Counter
+}
+
+type TokenCacheStats struct {
+	Size    int   `json:"size"`
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+func newTokenCache(size int) *tokenCache {
+	lookups, _ := otel.Meter("github.com/lavish440/Home-Security-Chatbot/internal/services").
+		Int64Counter("token_cache.lookups", metric.WithDescription("Token count cache lookups by result"))
+
+	return &tokenCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		lookups: lookups,
+	}
+}
+
+func (c *tokenCache) get(text string) (int32, bool) {
+	if c.size <= 0 {
+		return 0, false
+	}
+
+	c.mu.Lock()
+	el, ok := c.entries[text]
+	if ok {
+		c.order.MoveToFront(el)
+		c.hits++
+	} else {
+		c.misses++
+	}
+	c.mu.Unlock()
+
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	c.lookups.Add(context.Background(), 1, metric.WithAttributes(attribute.String("result", result)))
+
+	if !ok {
+		return 0, false
+	}
+	return el.Value.(*tokenEntry).tokens, true
+}
+
+func (c *tokenCache) put(text string, tokens int32) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[text]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[text] = c.order.PushFront(&tokenEntry{text: text, tokens: tokens})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*tokenEntry).text)
+	}
+}
+
+func (c *tokenCache) stats() TokenCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return TokenCacheStats{Size: c.size, Entries: c.order.Len(), Hits: c.hits, Misses: c.misses}
+}
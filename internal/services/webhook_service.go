@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lavish440/Home-Security-Chatbot/internal/models"
+)
+
+const webhookAttempts = 3
+
+type transcript struct {
+	ConversationID  string        `json:"conversation_id"`
+	Profile         string        `json:"profile"`
+	StartedAt       time.Time     `json:"started_at"`
+	EndedAt         time.Time     `json:"ended_at"`
+	DurationSeconds float64       `json:"duration_seconds"`
+	TurnCount       int           `json:"turn_count"`
+	Turns           []models.Turn `json:"turns"`
+}
+
+// ExportWebhook posts the transcript of ended sessions to an archival endpoint.
+type ExportWebhook struct {
+	url      string
+	client   *http.Client
+	redactor *Redactor
+}
+
+func NewExportWebhook(url string, redactor *Redactor) *ExportWebhook {
+	return &ExportWebhook{
+		url:      url,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		redactor: redactor,
+	}
+}
+
+// Export sends the session's transcript in the background.
+func (w *ExportWebhook) Export(cs *ChatSession) {
+	turns := cs.Turns()
+	for i := range turns {
+		turns[i].Message, _ = w.redactor.Redact(turns[i].Message)
+		turns[i].Response, _ = w.redactor.Redact(turns[i].Response)
+	}
+
+	endedAt := cs.LastUsed()
+	t := transcript{
+		ConversationID:  cs.ConversationID,
+		Profile:         cs.Profile,
+		StartedAt:       cs.CreatedAt,
+		EndedAt:         endedAt,
+		DurationSeconds: endedAt.Sub(cs.CreatedAt).Seconds(),
+		TurnCount:       len(turns),
+		Turns:           turns,
+	}
+
+	go w.send(t)
+}
+
+func (w *ExportWebhook) send(t transcript) {
+	body, err := json.Marshal(t)
+	if err != nil {
+		log.Printf("failed to encode transcript of conversation %s: %v", t.ConversationID, err)
+		return
+	}
+
+	backoff := time.Second
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		err = w.post(body)
+		if err == nil {
+			return
+		}
+
+		log.Printf("export webhook attempt %d/%d for conversation %s failed: %v", attempt, webhookAttempts, t.ConversationID, err)
+		if attempt < webhookAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	log.Printf("giving up on exporting conversation %s", t.ConversationID)
+}
+
+func (w *ExportWebhook) post(body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return requestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
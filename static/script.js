@@ -2,7 +2,29 @@ document.addEventListener('DOMContentLoaded', () => {
     const chatForm = document.getElementById('chatForm');
     const userInput = document.getElementById('userInput');
     const chatMessages = document.getElementById('chatMessages');
+    const profileSelect = document.getElementById('profileSelect');
 
+    async function loadProfiles() {
+        try {
+            const response = await fetch('/api/profiles');
+            if (!response.ok) return;
+
+            const data = await response.json();
+            if (data.profiles.length < 2) return;
+
+            for (const profile of data.profiles) {
+                const option = document.createElement('option');
+                option.value = profile.name;
+                option.textContent = profile.name;
+                option.title = profile.description;
+                option.selected = profile.name === 'default';
+                profileSelect.appendChild(option);
+            }
+            profileSelect.hidden = false;
+        } catch (error) {
+            console.error('Error:', error);
+        }
+    }
 
     function addMessage(content, isUser = false) {
         const messageDiv = document.createElement('div');
@@ -19,6 +41,10 @@ document.addEventListener('DOMContentLoaded', () => {
         chatMessages.scrollTop = chatMessages.scrollHeight;
     }
 
+    // Sent back with each message so the server can carry this conversation
+    // over if the client's IP changes
+    let conversationId = '';
+
     async function handleSubmit(e) {
         e.preventDefault();
         
@@ -33,15 +59,42 @@ document.addEventListener('DOMContentLoaded', () => {
                 method: 'POST',
                 headers: {
                     'Content-Type': 'application/json',
+                    'X-Timezone': Intl.DateTimeFormat().resolvedOptions().timeZone,
                 },
-                body: JSON.stringify({ message }),
+                body: JSON.stringify({
+                    message,
+                    profile: profileSelect.value || undefined,
+                    conversation_id: conversationId || undefined,
+                }),
             });
 
+            // A 503 carries a friendly message: the warm-up notice in response,
+            // or the overload and model-unavailable notices in error
+            if (response.status === 503) {
+                const data = await response.json();
+                addMessage(data.response || data.error);
+                return;
+            }
+
+            // Rejections of an emergency message carry the safety message in
+            // response, it is shown whatever the status
             if (!response.ok) {
-                throw new Error('Network response was not ok');
+                const data = await response.json().catch(() => ({}));
+                if (!data.response) {
+                    throw new Error('Network response was not ok');
+                }
+                addMessage(data.response);
+                if (data.error && response.status < 500) {
+                    addMessage(data.error);
+                }
+                return;
             }
 
             const data = await response.json();
+            conversationId = data.conversation_id || conversationId;
+            if (data.previous_summary) {
+                addMessage(`**Summary of your previous conversation:** ${data.previous_summary}`);
+            }
             addMessage(data.response);
         } catch (error) {
             console.error('Error:', error);
@@ -51,6 +104,12 @@ document.addEventListener('DOMContentLoaded', () => {
 
     chatForm.addEventListener('submit', handleSubmit);
 
+    // The server starts a new conversation when the profile changes
+    profileSelect.addEventListener('change', () => {
+        conversationId = '';
+        addMessage(`Switched to the ${profileSelect.value} profile. Your next message starts a new conversation.`);
+    });
+
     userInput.addEventListener('keypress', (e) => {
         if (e.key === 'Enter' && !e.shiftKey) {
             e.preventDefault();
@@ -58,5 +117,6 @@ document.addEventListener('DOMContentLoaded', () => {
         }
     });
 
+    loadProfiles();
     userInput.focus();
 }); 